@@ -2,9 +2,11 @@ package converter
 
 import (
 	"github.com/pb33f/libopenapi/datamodel/high/base"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/extensions"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/gnostic"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/googleapi"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/options"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/pgv"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/protovalidate"
 	"google.golang.org/protobuf/reflect/protoreflect"
 )
@@ -18,19 +20,28 @@ func (*annotator) AnnotateMessage(opts options.Options, schema *base.Schema, des
 	if opts.FeatureEnabled(options.FeatureGnostic) {
 		schema = gnostic.SchemaWithSchemaAnnotations(opts, schema, desc)
 	}
-	return schema
+	if opts.FeatureEnabled(options.FeatureGoogleAPIHTTP) {
+		schema = googleapi.SchemaWithMessageAnnotations(opts, schema, desc)
+	}
+	return extensions.SchemaWithMessageOptions(opts, schema, desc)
 }
 
 func (*annotator) AnnotateField(opts options.Options, schema *base.Schema, desc protoreflect.FieldDescriptor, onlyScalar bool) *base.Schema {
 	if opts.FeatureEnabled(options.FeatureProtovalidate) {
 		schema = protovalidate.SchemaWithFieldAnnotations(opts, schema, desc, onlyScalar)
 	}
+	if opts.FeatureEnabled(options.FeaturePGV) {
+		schema = pgv.SchemaWithFieldAnnotations(opts, schema, desc, onlyScalar)
+	}
 	if opts.FeatureEnabled(options.FeatureGnostic) {
 		schema = gnostic.SchemaWithPropertyAnnotations(opts, schema, desc)
 	}
 	if opts.FeatureEnabled(options.FeatureGoogleAPIHTTP) {
 		schema = googleapi.SchemaWithPropertyAnnotations(opts, schema, desc)
 	}
+	if !onlyScalar {
+		schema = extensions.SchemaWithFieldOptions(opts, schema, desc)
+	}
 	return schema
 }
 
@@ -38,5 +49,8 @@ func (*annotator) AnnotateFieldReference(opts options.Options, parent *base.Sche
 	if opts.FeatureEnabled(options.FeatureProtovalidate) {
 		parent = protovalidate.PopulateParentProperties(opts, parent, desc)
 	}
+	if opts.FeatureEnabled(options.FeaturePGV) {
+		parent = pgv.PopulateParentProperties(opts, parent, desc)
+	}
 	return parent
 }
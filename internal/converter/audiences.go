@@ -0,0 +1,233 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"go.yaml.in/yaml/v4"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	pluginpb "google.golang.org/protobuf/types/pluginpb"
+
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/options"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/visibility"
+)
+
+// audienceReportName is the name of the report of the elements that differ between audiences.
+const audienceReportName = "visibility-audiences"
+
+// audienceReport lists the elements that only some of the audiences can see.
+type audienceReport struct {
+	Audiences []string          `json:"audiences" yaml:"audiences"`
+	Elements  []audienceElement `json:"elements" yaml:"elements"`
+}
+
+type audienceElement struct {
+	Name        string   `json:"name" yaml:"name"`
+	Kind        string   `json:"kind" yaml:"kind"`
+	Restriction string   `json:"restriction" yaml:"restriction"`
+	Audiences   []string `json:"audiences" yaml:"audiences"`
+}
+
+// generateAudienceFiles generates the files once for each audience of `visibility-audiences`, with only the
+// audience as the allowed visibility. The names of the files get the audience as a suffix, and a report of the
+// elements that differ between the audiences is added so they can be reviewed.
+func generateAudienceFiles(req *pluginpb.CodeGeneratorRequest, opts options.Options) ([]*pluginpb.CodeGeneratorResponse_File, error) {
+	files := []*pluginpb.CodeGeneratorResponse_File{}
+	for _, audience := range opts.VisibilityAudiences {
+		audienceOpts := opts
+		audienceOpts.AllowedVisibilities = map[string]bool{audience: true}
+		audienceFiles, err := generateFiles(req, audienceOpts)
+		if err != nil {
+			return nil, fmt.Errorf("generating audience %s: %w", audience, err)
+		}
+		for _, file := range audienceFiles {
+			file.Name = proto.String(audienceFileName(file.GetName(), audience))
+		}
+		files = append(files, audienceFiles...)
+	}
+
+	report, err := makeAudienceReport(req, opts)
+	if err != nil {
+		return nil, err
+	}
+	content, err := renderAudienceReport(opts, report)
+	if err != nil {
+		return nil, err
+	}
+	name := audienceReportName + "." + opts.Format
+	if opts.Path != "" {
+		name = path.Join(path.Dir(opts.Path), name)
+	}
+	files = append(files, &pluginpb.CodeGeneratorResponse_File{
+		Name:    &name,
+		Content: &content,
+	})
+	return files, nil
+}
+
+// audienceFileName adds the lowercase audience to the name of a file, before `.openapi` or else before the
+// extension: foo.openapi.yaml becomes foo.public.openapi.yaml.
+func audienceFileName(name, audience string) string {
+	suffix := "." + strings.ToLower(audience)
+	if i := strings.LastIndex(name, ".openapi."); i >= 0 {
+		return name[:i] + suffix + name[i:]
+	}
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + suffix + ext
+}
+
+// makeAudienceReport lists the elements that end up in the files of only some of the audiences. It walks the
+// services and types of the files to generate and every type that their fields reach, including types from
+// imported files, and decides visibility like the generation does, so fields that hold a hidden message or enum
+// are reported too.
+func makeAudienceReport(req *pluginpb.CodeGeneratorRequest, opts options.Options) (audienceReport, error) {
+	report := audienceReport{Audiences: opts.VisibilityAudiences, Elements: []audienceElement{}}
+	resolver, err := protodesc.NewFiles(&descriptorpb.FileDescriptorSet{File: req.GetProtoFile()})
+	if err != nil {
+		return report, err
+	}
+	audienceOpts := make([]options.Options, 0, len(opts.VisibilityAudiences))
+	for _, audience := range opts.VisibilityAudiences {
+		o := opts
+		o.AllowedVisibilities = map[string]bool{audience: true}
+		audienceOpts = append(audienceOpts, o)
+	}
+	// add reports the element when some audiences can't see it and returns whether any audience can.
+	add := func(desc protoreflect.Descriptor, kind string) bool {
+		audiences := []string{}
+		for i, o := range audienceOpts {
+			if !visibility.IsHidden(o, desc) {
+				audiences = append(audiences, opts.VisibilityAudiences[i])
+			}
+		}
+		if len(audiences) < len(opts.VisibilityAudiences) {
+			report.Elements = append(report.Elements, audienceElement{
+				Name:        string(desc.FullName()),
+				Kind:        kind,
+				Restriction: hidingRestriction(desc),
+				Audiences:   audiences,
+			})
+		}
+		return len(audiences) > 0
+	}
+
+	// visible records whether any audience can see the types that were walked.
+	visible := map[protoreflect.FullName]bool{}
+	var addEnum func(enum protoreflect.EnumDescriptor)
+	addEnum = func(enum protoreflect.EnumDescriptor) {
+		if _, ok := visible[enum.FullName()]; ok {
+			return
+		}
+		visible[enum.FullName()] = add(enum, "enum")
+		if !visible[enum.FullName()] {
+			return
+		}
+		for i := 0; i < enum.Values().Len(); i++ {
+			add(enum.Values().Get(i), "enum value")
+		}
+	}
+	var addMessage func(message protoreflect.MessageDescriptor)
+	addField := func(field protoreflect.FieldDescriptor) {
+		if field.IsMap() {
+			field = field.MapValue()
+		}
+		switch {
+		case field.Message() != nil:
+			addMessage(field.Message())
+		case field.Enum() != nil:
+			addEnum(field.Enum())
+		}
+	}
+	addMessage = func(message protoreflect.MessageDescriptor) {
+		if _, ok := visible[message.FullName()]; ok {
+			return
+		}
+		visible[message.FullName()] = message.IsMapEntry() || add(message, "message")
+		if !visible[message.FullName()] {
+			return
+		}
+		for i := 0; i < message.Fields().Len(); i++ {
+			field := message.Fields().Get(i)
+			if message.IsMapEntry() || add(field, "field") {
+				addField(field)
+			}
+		}
+	}
+	var addNested func(messages protoreflect.MessageDescriptors, enums protoreflect.EnumDescriptors)
+	addNested = func(messages protoreflect.MessageDescriptors, enums protoreflect.EnumDescriptors) {
+		for i := 0; i < enums.Len(); i++ {
+			addEnum(enums.Get(i))
+		}
+		for i := 0; i < messages.Len(); i++ {
+			message := messages.Get(i)
+			addMessage(message)
+			if visible[message.FullName()] {
+				addNested(message.Messages(), message.Enums())
+			}
+		}
+	}
+	for _, name := range req.GetFileToGenerate() {
+		fd, err := resolver.FindFileByPath(name)
+		if err != nil {
+			return report, err
+		}
+		for i := 0; i < fd.Services().Len(); i++ {
+			service := fd.Services().Get(i)
+			if !opts.HasService(service.FullName()) || !add(service, "service") {
+				continue
+			}
+			for j := 0; j < service.Methods().Len(); j++ {
+				method := service.Methods().Get(j)
+				if add(method, "method") {
+					addMessage(method.Input())
+					addMessage(method.Output())
+				}
+			}
+		}
+		// Without trim-unused-types, every type of the file is generated
+		if !opts.TrimUnusedTypes {
+			addNested(fd.Messages(), fd.Enums())
+		}
+	}
+	return report, nil
+}
+
+// hidingRestriction returns the visibility restriction of the element or, for fields without one, of the
+// message or enum that the field holds.
+func hidingRestriction(desc protoreflect.Descriptor) string {
+	if restriction := visibility.ExtractVisibilityRestriction(desc); restriction != "" {
+		return restriction
+	}
+	field, ok := desc.(protoreflect.FieldDescriptor)
+	if !ok {
+		return ""
+	}
+	if field.IsMap() {
+		field = field.MapValue()
+	}
+	switch {
+	case field.Message() != nil:
+		return hidingRestriction(field.Message())
+	case field.Enum() != nil:
+		return hidingRestriction(field.Enum())
+	}
+	return ""
+}
+
+func renderAudienceReport(opts options.Options, report audienceReport) (string, error) {
+	switch opts.Format {
+	case "yaml":
+		b, err := yaml.Marshal(report)
+		return string(b), err
+	case "json":
+		b, err := json.MarshalIndent(report, "", "  ")
+		return string(b), err
+	default:
+		return "", fmt.Errorf("unknown format: %s", opts.Format)
+	}
+}
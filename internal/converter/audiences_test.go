@@ -0,0 +1,117 @@
+package converter_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/options"
+	"go.yaml.in/yaml/v4"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func TestVisibilityAudiences(t *testing.T) {
+	f, err := os.ReadFile(filepath.Join("testdata", "fileset.binpb"))
+	require.NoError(t, err)
+
+	pf := new(descriptorpb.FileDescriptorSet)
+	require.NoError(t, proto.Unmarshal(f, pf))
+
+	req := new(pluginpb.CodeGeneratorRequest)
+	req.ProtoFile = pf.GetFile()
+	req.FileToGenerate = []string{"visibility/visibility.proto"}
+
+	opts, err := options.FromString("visibility-audiences=INTERNAL;EXTERNAL")
+	require.NoError(t, err)
+
+	resp, err := converter.ConvertWithOptions(req, opts)
+	require.NoError(t, err)
+
+	files := map[string]string{}
+	for _, file := range resp.File {
+		files[file.GetName()] = file.GetContent()
+	}
+	require.Len(t, files, 3)
+	assert.Contains(t, files["visibility/visibility.internal.openapi.yaml"], "internalField:")
+	assert.NotContains(t, files["visibility/visibility.external.openapi.yaml"], "internalField:")
+	assert.Contains(t, files["visibility/visibility.external.openapi.yaml"], "/v1/external")
+	assert.NotContains(t, files["visibility/visibility.internal.openapi.yaml"], "/v1/external")
+
+	var report struct {
+		Audiences []string `yaml:"audiences"`
+		Elements  []struct {
+			Name      string   `yaml:"name"`
+			Kind      string   `yaml:"kind"`
+			Audiences []string `yaml:"audiences"`
+		} `yaml:"elements"`
+	}
+	require.Contains(t, files, "visibility-audiences.yaml")
+	require.NoError(t, yaml.Unmarshal([]byte(files["visibility-audiences.yaml"]), &report))
+	assert.Equal(t, []string{"INTERNAL", "EXTERNAL"}, report.Audiences)
+
+	elements := map[string][]string{}
+	for _, element := range report.Elements {
+		elements[element.Name] = element.Audiences
+	}
+	assert.Equal(t, []string{"INTERNAL"}, elements["visibility.Message.internal_field"])
+	assert.Equal(t, []string{"EXTERNAL"}, elements["visibility.ExternalService"])
+	assert.Equal(t, []string{}, elements["visibility.Message.preview_field"])
+	assert.NotContains(t, elements, "visibility.Message.multi_restricted_field")
+	assert.NotContains(t, elements, "visibility.Message.public_field")
+}
+
+func TestVisibilityAudiencesImportedTypes(t *testing.T) {
+	f, err := os.ReadFile(filepath.Join("testdata", "fileset.binpb"))
+	require.NoError(t, err)
+
+	pf := new(descriptorpb.FileDescriptorSet)
+	require.NoError(t, proto.Unmarshal(f, pf))
+
+	req := new(pluginpb.CodeGeneratorRequest)
+	req.ProtoFile = pf.GetFile()
+	req.FileToGenerate = []string{"audiences/audiences.proto"}
+
+	opts, err := options.FromString("visibility-audiences=INTERNAL;EXTERNAL")
+	require.NoError(t, err)
+
+	resp, err := converter.ConvertWithOptions(req, opts)
+	require.NoError(t, err)
+
+	files := map[string]string{}
+	for _, file := range resp.File {
+		files[file.GetName()] = file.GetContent()
+	}
+	assert.Contains(t, files["audiences/audiences.internal.openapi.yaml"], "internalNotes:")
+	assert.NotContains(t, files["audiences/audiences.external.openapi.yaml"], "internalNotes:")
+	assert.Contains(t, files["audiences/audiences.external.openapi.yaml"], "partner:")
+	assert.NotContains(t, files["audiences/audiences.internal.openapi.yaml"], "partner:")
+
+	var report struct {
+		Elements []struct {
+			Name        string   `yaml:"name"`
+			Kind        string   `yaml:"kind"`
+			Restriction string   `yaml:"restriction"`
+			Audiences   []string `yaml:"audiences"`
+		} `yaml:"elements"`
+	}
+	require.NoError(t, yaml.Unmarshal([]byte(files["visibility-audiences.yaml"]), &report))
+	elements := map[string][]string{}
+	restrictions := map[string]string{}
+	for _, element := range report.Elements {
+		elements[element.Name] = element.Audiences
+		restrictions[element.Name] = element.Restriction
+	}
+	// The restricted field of an imported message that a field reaches
+	assert.Equal(t, []string{"INTERNAL"}, elements["audiences.shared.Address.internal_notes"])
+	// The field is hidden because the message it holds is hidden
+	assert.Equal(t, []string{"EXTERNAL"}, elements["audiences.Account.partner"])
+	assert.Equal(t, "EXTERNAL", restrictions["audiences.Account.partner"])
+	assert.Equal(t, []string{"EXTERNAL"}, elements["audiences.shared.PartnerInfo"])
+	// Imported types that aren't reached aren't generated
+	assert.NotContains(t, elements, "audiences.shared.Unused.internal_only")
+}
@@ -1,11 +1,14 @@
 package connectrpc
 
 import (
+	"slices"
+
 	"github.com/pb33f/libopenapi/datamodel/high/base"
 	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
 	"github.com/pb33f/libopenapi/orderedmap"
 	"github.com/pb33f/libopenapi/utils"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/options"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/util"
 	"go.yaml.in/yaml/v4"
 	"google.golang.org/protobuf/reflect/protoreflect"
 )
@@ -14,6 +17,13 @@ func AddSchemas(opts options.Options, doc *v3.Document, method protoreflect.Meth
 	if methodHasGet(opts, method) {
 		addConnectGetSchemas(doc.Components)
 	}
+	isStreaming := method.IsStreamingClient() || method.IsStreamingServer()
+	if opts.WithErrorResponses && !isStreaming {
+		addErrorSchemas(opts, doc.Components, method)
+	}
+	if isStreaming && opts.WithStreaming {
+		addConnectStreamingSchemas(doc.Components, method)
+	}
 	components := doc.Components
 	if _, ok := components.Schemas.Get("connect-protocol-version"); !ok {
 		components.Schemas.Set("connect-protocol-version", base.CreateSchemaProxy(&base.Schema{
@@ -34,64 +44,10 @@ func AddSchemas(opts options.Options, doc *v3.Document, method protoreflect.Meth
 	}
 
 	if _, ok := components.Schemas.Get("ct.error"); !ok {
-		connectErrorProps := orderedmap.New[string, *base.SchemaProxy]()
-		connectErrorProps.Set("code", base.CreateSchemaProxy(&base.Schema{
-			Description: "The status code, which should be an enum value of [google.rpc.Code][google.rpc.Code].",
-			Type:        []string{"string"},
-			Examples:    []*yaml.Node{utils.CreateStringNode("not_found")},
-			Enum: []*yaml.Node{
-				utils.CreateStringNode("canceled"),
-				utils.CreateStringNode("unknown"),
-				utils.CreateStringNode("invalid_argument"),
-				utils.CreateStringNode("deadline_exceeded"),
-				utils.CreateStringNode("not_found"),
-				utils.CreateStringNode("already_exists"),
-				utils.CreateStringNode("permission_denied"),
-				utils.CreateStringNode("resource_exhausted"),
-				utils.CreateStringNode("failed_precondition"),
-				utils.CreateStringNode("aborted"),
-				utils.CreateStringNode("out_of_range"),
-				utils.CreateStringNode("unimplemented"),
-				utils.CreateStringNode("internal"),
-				utils.CreateStringNode("unavailable"),
-				utils.CreateStringNode("data_loss"),
-				utils.CreateStringNode("unauthenticated"),
-			},
-		}))
-		connectErrorProps.Set("message", base.CreateSchemaProxy(&base.Schema{
-			Description: "A developer-facing error message, which should be in English. Any user-facing error message should be localized and sent in the [google.rpc.Status.details][google.rpc.Status.details] field, or localized by the client.",
-			Type:        []string{"string"},
-		}))
-
-		connectErrorProps.Set("details", base.CreateSchemaProxy(&base.Schema{
-			Type: []string{"array"},
-			Items: &base.DynamicValue[*base.SchemaProxy, bool]{
-				N: 0,
-				A: base.CreateSchemaProxyRef("#/components/schemas/connect.error_details.Any"),
-			},
-			Description: "A list of messages that carry the error details. There is no limit on the number of messages.",
-		}))
-		components.Schemas.Set("connect.error", base.CreateSchemaProxy(&base.Schema{
-			Title:                "Connect Error",
-			Description:          `Error type returned by Connect: https://connectrpc.com/docs/go/errors/#http-representation`,
-			Properties:           connectErrorProps,
-			Type:                 []string{"object"},
-			AdditionalProperties: &base.DynamicValue[*base.SchemaProxy, bool]{N: 1, B: true},
-		}))
+		components.Schemas.Set("connect.error", base.CreateSchemaProxy(newConnectErrorSchema(nil, "#/components/schemas/connect.error_details.Any")))
 	}
 
 	if _, ok := components.Schemas.Get("connect.error_details.Any"); !ok {
-		connectAnyProps := orderedmap.New[string, *base.SchemaProxy]()
-		connectAnyProps.Set("type", base.CreateSchemaProxy(&base.Schema{
-			Type:        []string{"string"},
-			Description: "A URL that acts as a globally unique identifier for the type of the serialized message. For example: `type.googleapis.com/google.rpc.ErrorInfo`. This is used to determine the schema of the data in the `value` field and is the discriminator for the `debug` field.",
-		}))
-		connectAnyProps.Set("value", base.CreateSchemaProxy(&base.Schema{
-			Type:        []string{"string"},
-			Format:      "binary",
-			Description: "The Protobuf message, serialized as bytes and base64-encoded. The specific message type is identified by the `type` field.",
-		}))
-
 		errorDetailOptions := []*base.SchemaProxy{
 			base.CreateSchemaProxy(&base.Schema{
 				Title:                "Any",
@@ -102,7 +58,7 @@ func AddSchemas(opts options.Options, doc *v3.Document, method protoreflect.Meth
 		}
 		mapping := orderedmap.New[string, string]()
 		if opts.WithGoogleErrorDetail {
-			googleRPCSchemas := newGoogleRPCErrorDetailSchemas()
+			googleRPCSchemas := NewGoogleRPCErrorDetailSchemas()
 			for pair := googleRPCSchemas.First(); pair != nil; pair = pair.Next() {
 				components.Schemas.Set(pair.Key(), pair.Value())
 				errorDetailOptions = append(errorDetailOptions, base.CreateSchemaProxyRef("#/components/schemas/"+pair.Key()))
@@ -112,29 +68,92 @@ func AddSchemas(opts options.Options, doc *v3.Document, method protoreflect.Meth
 				mapping.Set("type.googleapis.com/"+pair.Key(), "#/components/schemas/"+pair.Key())
 			}
 		}
+		components.Schemas.Set("connect.error_details.Any", base.CreateSchemaProxy(newErrorDetailsAnySchema(errorDetailOptions, mapping)))
+	}
 
-		// Now create the schema for the "debug" field with the discriminator
-		debugSchema := base.CreateSchemaProxy(&base.Schema{
-			Title:       "Debug",
-			Description: `Deserialized error detail payload. The 'type' field indicates the schema. This field is for easier debugging and should not be relied upon for application logic.`,
-			OneOf:       errorDetailOptions,
-			Discriminator: &base.Discriminator{
-				PropertyName: "type",
-				Mapping:      mapping,
-			},
-		})
-		connectAnyProps.Set("debug", debugSchema)
+	addMethodErrorSchemas(opts, components, method)
+	if opts.WithProtocolHeaders {
+		addProtocolHeaderComponents(opts, components, method)
+	}
+}
 
-		components.Schemas.Set("connect.error_details.Any", base.CreateSchemaProxy(&base.Schema{
-			Description:          "Contains an arbitrary serialized message along with a @type that describes the type of the serialized message, with an additional debug field for ConnectRPC error details.",
-			Type:                 []string{"object"},
-			Properties:           connectAnyProps,
-			AdditionalProperties: &base.DynamicValue[*base.SchemaProxy, bool]{N: 1, B: true},
-		}))
+// newConnectErrorSchema creates the schema of a Connect error. The codes narrow down the possible values of
+// `code` when they're given.
+func newConnectErrorSchema(codes []string, detailsRef string) *base.Schema {
+	codeSchema := &base.Schema{
+		Description: "The status code, which should be an enum value of [google.rpc.Code][google.rpc.Code].",
+		Type:        []string{"string"},
+		Examples:    []*yaml.Node{utils.CreateStringNode("not_found")},
+	}
+	for _, errorCode := range errorCodes {
+		if len(codes) == 0 || slices.Contains(codes, errorCode.Code) {
+			codeSchema.Enum = append(codeSchema.Enum, utils.CreateStringNode(errorCode.Code))
+		}
+	}
+	if len(codes) > 0 {
+		codeSchema.Examples = []*yaml.Node{codeSchema.Enum[0]}
+	}
+	connectErrorProps := orderedmap.New[string, *base.SchemaProxy]()
+	connectErrorProps.Set("code", base.CreateSchemaProxy(codeSchema))
+	connectErrorProps.Set("message", base.CreateSchemaProxy(&base.Schema{
+		Description: "A developer-facing error message, which should be in English. Any user-facing error message should be localized and sent in the [google.rpc.Status.details][google.rpc.Status.details] field, or localized by the client.",
+		Type:        []string{"string"},
+	}))
+
+	connectErrorProps.Set("details", base.CreateSchemaProxy(&base.Schema{
+		Type: []string{"array"},
+		Items: &base.DynamicValue[*base.SchemaProxy, bool]{
+			N: 0,
+			A: base.CreateSchemaProxyRef(detailsRef),
+		},
+		Description: "A list of messages that carry the error details. There is no limit on the number of messages.",
+	}))
+	return &base.Schema{
+		Title:                "Connect Error",
+		Description:          `Error type returned by Connect: https://connectrpc.com/docs/go/errors/#http-representation`,
+		Properties:           connectErrorProps,
+		Type:                 []string{"object"},
+		AdditionalProperties: &base.DynamicValue[*base.SchemaProxy, bool]{N: 1, B: true},
+	}
+}
+
+// newErrorDetailsAnySchema creates the schema of an error detail. The `debug` field holds the deserialized
+// detail, which is one of the given options. The mapping maps type URLs to schema references.
+func newErrorDetailsAnySchema(errorDetailOptions []*base.SchemaProxy, mapping *orderedmap.Map[string, string]) *base.Schema {
+	connectAnyProps := orderedmap.New[string, *base.SchemaProxy]()
+	connectAnyProps.Set("type", base.CreateSchemaProxy(&base.Schema{
+		Type:        []string{"string"},
+		Description: "A URL that acts as a globally unique identifier for the type of the serialized message. For example: `type.googleapis.com/google.rpc.ErrorInfo`. This is used to determine the schema of the data in the `value` field and is the discriminator for the `debug` field.",
+	}))
+	connectAnyProps.Set("value", base.CreateSchemaProxy(&base.Schema{
+		Type:        []string{"string"},
+		Format:      "binary",
+		Description: "The Protobuf message, serialized as bytes and base64-encoded. The specific message type is identified by the `type` field.",
+	}))
+
+	// Now create the schema for the "debug" field with the discriminator
+	debugSchema := base.CreateSchemaProxy(&base.Schema{
+		Title:       "Debug",
+		Description: `Deserialized error detail payload. The 'type' field indicates the schema. This field is for easier debugging and should not be relied upon for application logic.`,
+		OneOf:       errorDetailOptions,
+		Discriminator: &base.Discriminator{
+			PropertyName: "type",
+			Mapping:      mapping,
+		},
+	})
+	connectAnyProps.Set("debug", debugSchema)
+
+	return &base.Schema{
+		Description:          "Contains an arbitrary serialized message along with a @type that describes the type of the serialized message, with an additional debug field for ConnectRPC error details.",
+		Type:                 []string{"object"},
+		Properties:           connectAnyProps,
+		AdditionalProperties: &base.DynamicValue[*base.SchemaProxy, bool]{N: 1, B: true},
 	}
 }
 
-func newGoogleRPCErrorDetailSchemas() *orderedmap.Map[string, *base.SchemaProxy] {
+// NewGoogleRPCErrorDetailSchemas returns the schemas of the standard error details from
+// google/rpc/error_details.proto, keyed by their full names.
+func NewGoogleRPCErrorDetailSchemas() *orderedmap.Map[string, *base.SchemaProxy] {
 	schemas := orderedmap.New[string, *base.SchemaProxy]()
 
 	// ErrorInfo
@@ -398,15 +417,7 @@ func addConnectGetSchemas(components *v3.Components) {
 	}
 
 	if _, ok := components.Schemas.Get("compression"); !ok {
-		components.Schemas.Set("compression", base.CreateSchemaProxy(&base.Schema{
-			Title:       "compression",
-			Description: "Which compression algorithm to use for this request",
-			Enum: []*yaml.Node{
-				utils.CreateStringNode("identity"),
-				utils.CreateStringNode("gzip"),
-				utils.CreateStringNode("br"),
-			},
-		}))
+		components.Schemas.Set("compression", base.CreateSchemaProxy(newCompressionSchema()))
 	}
 
 	if _, ok := components.Schemas.Get("connect"); !ok {
@@ -419,3 +430,79 @@ func addConnectGetSchemas(components *v3.Components) {
 		}))
 	}
 }
+
+func newCompressionSchema() *base.Schema {
+	enum := make([]*yaml.Node, 0, len(compressions))
+	for _, compression := range compressions {
+		enum = append(enum, utils.CreateStringNode(compression))
+	}
+	return &base.Schema{
+		Title:       "compression",
+		Description: "Which compression algorithm to use for this request",
+		Enum:        enum,
+	}
+}
+
+// streamSchemaName returns the name of the schema for the messages of the response stream of a method. Like the
+// schemas of REST streams, it is named after the output message, with a suffix for the Connect envelope format.
+func streamSchemaName(method protoreflect.MethodDescriptor) string {
+	return util.FormatTypeRef(string(method.Output().FullName())) + ".connect-stream"
+}
+
+func addConnectStreamingSchemas(components *v3.Components, method protoreflect.MethodDescriptor) {
+	if _, ok := components.Schemas.Get("connect.envelope-flags"); !ok {
+		components.Schemas.Set("connect.envelope-flags", base.CreateSchemaProxy(&base.Schema{
+			Title: "Envelope flags",
+			Description: "The first byte of the envelope of a message of a Connect stream. " +
+				"The `0x01` flag marks a compressed message and the `0x02` flag marks the end-stream message, which is always the last message of the stream.",
+			Type: []string{"integer"},
+			Enum: []*yaml.Node{
+				utils.CreateIntNode("0"),
+				utils.CreateIntNode("1"),
+				utils.CreateIntNode("2"),
+				utils.CreateIntNode("3"),
+			},
+		}))
+	}
+
+	if _, ok := components.Schemas.Get("connect.end-stream"); !ok {
+		endStreamProps := orderedmap.New[string, *base.SchemaProxy]()
+		endStreamProps.Set("error", base.CreateSchemaProxyRef("#/components/schemas/connect.error"))
+		endStreamProps.Set("metadata", base.CreateSchemaProxy(&base.Schema{
+			Description: "The trailers of the response. Keys are trailer names and values are lists of trailer values.",
+			Type:        []string{"object"},
+			AdditionalProperties: &base.DynamicValue[*base.SchemaProxy, bool]{
+				N: 0,
+				A: base.CreateSchemaProxy(&base.Schema{
+					Type:  []string{"array"},
+					Items: &base.DynamicValue[*base.SchemaProxy, bool]{N: 0, A: base.CreateSchemaProxy(&base.Schema{Type: []string{"string"}})},
+				}),
+			},
+		}))
+		components.Schemas.Set("connect.end-stream", base.CreateSchemaProxy(&base.Schema{
+			Title:                "EndStreamResponse",
+			Description:          "The last message of a response stream, sent in an envelope with the end-stream flag. It is always JSON, even when the messages of the stream are encoded as protobuf. The stream failed if it has an `error`.",
+			Type:                 []string{"object"},
+			Properties:           endStreamProps,
+			AdditionalProperties: &base.DynamicValue[*base.SchemaProxy, bool]{N: 1, B: false},
+		}))
+	}
+
+	name := streamSchemaName(method)
+	if _, ok := components.Schemas.Get(name); !ok {
+		outputRef := "#/components/schemas/" + util.FormatTypeRef(string(method.Output().FullName()))
+		extensions := orderedmap.New[string, *yaml.Node]()
+		extensions.Set("x-connect-envelope-flags", utils.CreateRefNode("#/components/schemas/connect.envelope-flags"))
+		components.Schemas.Set(name, base.CreateSchemaProxy(&base.Schema{
+			Title: string(method.Output().Name()) + " stream",
+			Description: "A message of a Connect response stream of " + string(method.Output().Name()) + " messages. " +
+				"Each message is sent in an envelope: one byte of flags, described by `x-connect-envelope-flags`, the length of the message as a 4-byte big-endian unsigned integer and the encoded message. " +
+				"See the [Connect Protocol](https://connectrpc.com/docs/protocol/#streaming-rpcs) for more.",
+			AnyOf: []*base.SchemaProxy{
+				base.CreateSchemaProxyRef(outputRef),
+				base.CreateSchemaProxyRef("#/components/schemas/connect.end-stream"),
+			},
+			Extensions: extensions,
+		}))
+	}
+}
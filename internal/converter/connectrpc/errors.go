@@ -0,0 +1,188 @@
+package connectrpc
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+	"github.com/pb33f/libopenapi/orderedmap"
+	"github.com/pb33f/libopenapi/utils"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/options"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/schema"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/util"
+)
+
+// errorCode is a Connect error code along with the HTTP status that unary RPCs use for it.
+type errorCode struct {
+	Code   string
+	Status int
+}
+
+// errorCodes are the Connect error codes in the order of their numeric values.
+// See https://connectrpc.com/docs/protocol/#error-codes.
+var errorCodes = []errorCode{
+	{"canceled", 499},
+	{"unknown", http.StatusInternalServerError},
+	{"invalid_argument", http.StatusBadRequest},
+	{"deadline_exceeded", http.StatusGatewayTimeout},
+	{"not_found", http.StatusNotFound},
+	{"already_exists", http.StatusConflict},
+	{"permission_denied", http.StatusForbidden},
+	{"resource_exhausted", http.StatusTooManyRequests},
+	{"failed_precondition", http.StatusBadRequest},
+	{"aborted", http.StatusConflict},
+	{"out_of_range", http.StatusBadRequest},
+	{"unimplemented", http.StatusNotImplemented},
+	{"internal", http.StatusInternalServerError},
+	{"unavailable", http.StatusServiceUnavailable},
+	{"data_loss", http.StatusInternalServerError},
+	{"unauthenticated", http.StatusUnauthorized},
+}
+
+// methodErrorCodes returns the error codes that are documented for the method, grouped by HTTP status. All
+// codes are used unless the config narrows them down for the method.
+func methodErrorCodes(opts options.Options, method protoreflect.MethodDescriptor) *orderedmap.Map[int, []string] {
+	codes, narrowed := opts.Config.ErrorCodes(method.FullName())
+	for _, code := range codes {
+		if !slices.ContainsFunc(errorCodes, func(c errorCode) bool { return c.Code == code }) {
+			opts.Logger.Warn("unknown Connect error code in config", slog.String("code", code), slog.String("method", string(method.FullName())))
+		}
+	}
+
+	byStatus := map[int][]string{}
+	statuses := []int{}
+	for _, errorCode := range errorCodes {
+		if narrowed && !slices.Contains(codes, errorCode.Code) {
+			continue
+		}
+		if _, ok := byStatus[errorCode.Status]; !ok {
+			statuses = append(statuses, errorCode.Status)
+		}
+		byStatus[errorCode.Status] = append(byStatus[errorCode.Status], errorCode.Code)
+	}
+	slices.Sort(statuses)
+	result := orderedmap.New[int, []string]()
+	for _, status := range statuses {
+		result.Set(status, byStatus[status])
+	}
+	return result
+}
+
+// errorSchemaName returns the name of the schema of a Connect error of the method with the given code. Methods
+// with their own error schema also get their own schema for each code.
+func errorSchemaName(opts options.Options, method protoreflect.MethodDescriptor, code string) string {
+	if hasMethodErrorSchema(opts, method) {
+		return util.FormatTypeRef(string(method.FullName())) + ".connect.error." + code
+	}
+	return "connect.error." + code
+}
+
+// addErrorResponses adds a response for each HTTP status that the error codes of the method map to.
+func addErrorResponses(opts options.Options, method protoreflect.MethodDescriptor, responses *v3.Responses) {
+	for pair := methodErrorCodes(opts, method).First(); pair != nil; pair = pair.Next() {
+		status, codes := pair.Key(), pair.Value()
+		var s *base.SchemaProxy
+		if len(codes) == 1 {
+			s = base.CreateSchemaProxyRef("#/components/schemas/" + errorSchemaName(opts, method, codes[0]))
+		} else {
+			oneOf := make([]*base.SchemaProxy, 0, len(codes))
+			mapping := orderedmap.New[string, string]()
+			for _, code := range codes {
+				ref := "#/components/schemas/" + errorSchemaName(opts, method, code)
+				oneOf = append(oneOf, base.CreateSchemaProxyRef(ref))
+				mapping.Set(code, ref)
+			}
+			s = base.CreateSchemaProxy(&base.Schema{
+				OneOf: oneOf,
+				Discriminator: &base.Discriminator{
+					PropertyName: "code",
+					Mapping:      mapping,
+				},
+			})
+		}
+		description := http.StatusText(status)
+		if status == 499 {
+			description = "Client Closed Request"
+		}
+		responses.Codes.Set(strconv.Itoa(status), &v3.Response{
+			Description: description,
+			Content:     util.MakeMediaTypes(opts, s, false, false),
+		})
+	}
+}
+
+// addErrorSchemas adds a schema for each error code that the method can return. Each schema is the error schema
+// of the method with the `code` set to a single value, so clients can tell errors apart by their code.
+func addErrorSchemas(opts options.Options, components *v3.Components, method protoreflect.MethodDescriptor) {
+	for _, codes := range methodErrorCodes(opts, method).FromOldest() {
+		for _, code := range codes {
+			name := errorSchemaName(opts, method, code)
+			if _, ok := components.Schemas.Get(name); ok {
+				continue
+			}
+			props := orderedmap.New[string, *base.SchemaProxy]()
+			props.Set("code", base.CreateSchemaProxy(&base.Schema{
+				Type:  []string{"string"},
+				Const: utils.CreateStringNode(code),
+			}))
+			components.Schemas.Set(name, base.CreateSchemaProxy(&base.Schema{
+				Title: code,
+				AllOf: []*base.SchemaProxy{
+					base.CreateSchemaProxyRef(errorRef(opts, method)),
+					base.CreateSchemaProxy(&base.Schema{
+						Type:       []string{"object"},
+						Properties: props,
+						Required:   []string{"code"},
+					}),
+				},
+			}))
+		}
+	}
+}
+
+// hasMethodErrorSchema reports whether the errors of the method are declared in the config, in which case the
+// method gets its own error schema.
+func hasMethodErrorSchema(opts options.Options, method protoreflect.MethodDescriptor) bool {
+	rule := opts.Config.ErrorResponseRule(method.FullName())
+	return rule != nil && (len(rule.Codes) > 0 || len(rule.Details) > 0)
+}
+
+// errorRef returns the reference to the schema of the errors of the method.
+func errorRef(opts options.Options, method protoreflect.MethodDescriptor) string {
+	if hasMethodErrorSchema(opts, method) {
+		return "#/components/schemas/" + util.FormatTypeRef(string(method.FullName())) + ".connect.error"
+	}
+	return "#/components/schemas/connect.error"
+}
+
+// addMethodErrorSchemas adds the error schema of a method with declared errors. The `code` of the error only
+// allows the declared codes and the `debug` field of its details is one of the declared detail messages.
+func addMethodErrorSchemas(opts options.Options, components *v3.Components, method protoreflect.MethodDescriptor) {
+	if !hasMethodErrorSchema(opts, method) {
+		return
+	}
+	name := util.FormatTypeRef(string(method.FullName())) + ".connect.error"
+	if _, ok := components.Schemas.Get(name); ok {
+		return
+	}
+	detailsRef := "#/components/schemas/connect.error_details.Any"
+	if details := opts.Config.ErrorDetails(method.FullName()); len(details) > 0 {
+		errorDetailOptions := make([]*base.SchemaProxy, 0, len(details))
+		mapping := orderedmap.New[string, string]()
+		for _, detail := range details {
+			ref := schema.MessageRefByName(opts, detail)
+			errorDetailOptions = append(errorDetailOptions, base.CreateSchemaProxyRef(ref))
+			mapping.Set("type.googleapis.com/"+detail, ref)
+		}
+		detailsName := util.FormatTypeRef(string(method.FullName())) + ".connect.error_details.Any"
+		components.Schemas.Set(detailsName, base.CreateSchemaProxy(newErrorDetailsAnySchema(errorDetailOptions, mapping)))
+		detailsRef = "#/components/schemas/" + detailsName
+	}
+	codes, _ := opts.Config.ErrorCodes(method.FullName())
+	components.Schemas.Set(name, base.CreateSchemaProxy(newConnectErrorSchema(codes, detailsRef)))
+}
@@ -0,0 +1,220 @@
+package connectrpc
+
+import (
+	"strings"
+
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+	"github.com/pb33f/libopenapi/orderedmap"
+	"github.com/pb33f/libopenapi/utils"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/options"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/util"
+	"go.yaml.in/yaml/v4"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// compressions are the compression algorithms that Connect implementations support.
+var compressions = []string{"identity", "gzip", "br"}
+
+// protocolHeader is a header of the Connect or gRPC protocol. It's defined once in the components and
+// referenced from the operations.
+type protocolHeader struct {
+	// Key is the name of the parameter or header in the components.
+	Key         string
+	Name        string
+	Description string
+	SchemaRef   string
+}
+
+var (
+	contentEncodingHeader = protocolHeader{
+		Key:         "content-encoding",
+		Name:        "Content-Encoding",
+		Description: "The compression of the body of a unary message.",
+		SchemaRef:   "#/components/schemas/compression",
+	}
+	acceptEncodingHeader = protocolHeader{
+		Key:         "accept-encoding",
+		Name:        "Accept-Encoding",
+		Description: "The compressions that are accepted for the body of a unary response.",
+		SchemaRef:   "#/components/schemas/accept-compression",
+	}
+	connectContentEncodingHeader = protocolHeader{
+		Key:         "connect-content-encoding",
+		Name:        "Connect-Content-Encoding",
+		Description: "The compression of the messages of a stream. The flags of each envelope say whether its message is compressed.",
+		SchemaRef:   "#/components/schemas/compression",
+	}
+	connectAcceptEncodingHeader = protocolHeader{
+		Key:         "connect-accept-encoding",
+		Name:        "Connect-Accept-Encoding",
+		Description: "The compressions that are accepted for the messages of a response stream.",
+		SchemaRef:   "#/components/schemas/accept-compression",
+	}
+	grpcTimeoutHeader = protocolHeader{
+		Key:         "grpc-timeout",
+		Name:        "Grpc-Timeout",
+		Description: "The timeout of the RPC, as an integer of at most 8 digits followed by the unit: `H`, `M`, `S`, `m`, `u` or `n`.",
+		SchemaRef:   "#/components/schemas/grpc-timeout",
+	}
+	grpcEncodingHeader = protocolHeader{
+		Key:         "grpc-encoding",
+		Name:        "Grpc-Encoding",
+		Description: "The compression of the gRPC messages.",
+		SchemaRef:   "#/components/schemas/compression",
+	}
+	grpcAcceptEncodingHeader = protocolHeader{
+		Key:         "grpc-accept-encoding",
+		Name:        "Grpc-Accept-Encoding",
+		Description: "The compressions that are accepted for the gRPC response messages.",
+		SchemaRef:   "#/components/schemas/accept-compression",
+	}
+	grpcStatusHeader = protocolHeader{
+		Key:         "grpc-status",
+		Name:        "Grpc-Status",
+		Description: "The numeric gRPC status code of the RPC. It's sent as a trailer, which gRPC-Web sends at the end of the body.",
+		SchemaRef:   "#/components/schemas/grpc-status",
+	}
+	grpcMessageHeader = protocolHeader{
+		Key:         "grpc-message",
+		Name:        "Grpc-Message",
+		Description: "The percent-encoded error message of the RPC. It's sent as a trailer, which gRPC-Web sends at the end of the body.",
+		SchemaRef:   "#/components/schemas/grpc-message",
+	}
+)
+
+// trailersDescription documents the trailers of unary Connect responses. Their names depend on the RPC, so they
+// can't be listed as headers.
+const trailersDescription = "The trailers of the RPC are sent as headers, with the names prefixed by `Trailer-`."
+
+// protocolHeaders returns the request and response headers of the protocols that an operation uses, based on
+// the content types of its responses. It also reports whether the responses are unary Connect responses, which
+// send the trailers of the RPC as headers.
+func protocolHeaders(opts options.Options, method protoreflect.MethodDescriptor, returnGet bool) (request, response []protocolHeader, trailers bool) {
+	isStreaming := method.IsStreamingClient() || method.IsStreamingServer()
+	var isUnary, isConnectStreaming, isGRPC bool
+	for contentType := range util.MakeMediaTypes(opts, nil, false, isStreaming).KeysFromOldest() {
+		switch {
+		case strings.HasPrefix(contentType, "application/grpc"):
+			isGRPC = true
+		case strings.HasPrefix(contentType, "application/connect+"):
+			isConnectStreaming = true
+		default:
+			isUnary = true
+		}
+	}
+	if isUnary {
+		if !returnGet {
+			request = append(request, contentEncodingHeader)
+		}
+		request = append(request, acceptEncodingHeader)
+		response = append(response, contentEncodingHeader)
+	}
+	if isConnectStreaming {
+		request = append(request, connectContentEncodingHeader, connectAcceptEncodingHeader)
+		response = append(response, connectContentEncodingHeader)
+	}
+	if isGRPC && !returnGet {
+		request = append(request, grpcTimeoutHeader, grpcEncodingHeader, grpcAcceptEncodingHeader)
+		response = append(response, grpcEncodingHeader, grpcStatusHeader, grpcMessageHeader)
+	}
+	return request, response, isUnary
+}
+
+// addProtocolHeaders adds references to the protocol headers to the parameters and the responses of the
+// operation.
+func addProtocolHeaders(opts options.Options, method protoreflect.MethodDescriptor, op *v3.Operation, returnGet bool) {
+	request, response, trailers := protocolHeaders(opts, method, returnGet)
+	for _, header := range request {
+		op.Parameters = append(op.Parameters, v3.CreateParameterRef("#/components/parameters/"+header.Key))
+	}
+	responses := []*v3.Response{op.Responses.Default}
+	for pair := op.Responses.Codes.First(); pair != nil; pair = pair.Next() {
+		responses = append(responses, pair.Value())
+	}
+	for _, resp := range responses {
+		if resp == nil {
+			continue
+		}
+		if resp.Headers == nil {
+			resp.Headers = orderedmap.New[string, *v3.Header]()
+		}
+		for _, header := range response {
+			resp.Headers.Set(header.Name, v3.CreateHeaderRef("#/components/headers/"+header.Key))
+		}
+		if trailers {
+			resp.Description = strings.TrimSpace(resp.Description + "\n\n" + trailersDescription)
+		}
+	}
+}
+
+// addProtocolHeaderComponents adds the parameters, headers and schemas that the protocol headers of the
+// method refer to.
+func addProtocolHeaderComponents(opts options.Options, components *v3.Components, method protoreflect.MethodDescriptor) {
+	request, response, _ := protocolHeaders(opts, method, false)
+	for _, header := range request {
+		if _, ok := components.Parameters.Get(header.Key); !ok {
+			components.Parameters.Set(header.Key, &v3.Parameter{
+				Name:        header.Name,
+				In:          "header",
+				Description: header.Description,
+				Schema:      base.CreateSchemaProxyRef(header.SchemaRef),
+			})
+		}
+	}
+	for _, header := range response {
+		if _, ok := components.Headers.Get(header.Key); !ok {
+			components.Headers.Set(header.Key, &v3.Header{
+				Description: header.Description,
+				Schema:      base.CreateSchemaProxyRef(header.SchemaRef),
+			})
+		}
+	}
+	for _, header := range append(request, response...) {
+		name := strings.TrimPrefix(header.SchemaRef, "#/components/schemas/")
+		if _, ok := components.Schemas.Get(name); !ok {
+			components.Schemas.Set(name, base.CreateSchemaProxy(protocolHeaderSchemas[name]()))
+		}
+	}
+}
+
+// protocolHeaderSchemas creates the schemas of the values of the protocol headers.
+var protocolHeaderSchemas = map[string]func() *base.Schema{
+	"compression": newCompressionSchema,
+	"accept-compression": func() *base.Schema {
+		names := strings.Join(compressions, "|")
+		return &base.Schema{
+			Title:       "accept-compression",
+			Description: "A comma-separated list of compression algorithms",
+			Type:        []string{"string"},
+			Pattern:     `^(` + names + `)(\s*,\s*(` + names + `))*$`,
+			Examples:    []*yaml.Node{utils.CreateStringNode(strings.Join(compressions[1:], ", "))},
+		}
+	},
+	"grpc-timeout": func() *base.Schema {
+		return &base.Schema{
+			Title:       "grpc-timeout",
+			Description: "Define the timeout, with a unit",
+			Type:        []string{"string"},
+			Pattern:     `^\d{1,8}[HMSmun]$`,
+			Examples:    []*yaml.Node{utils.CreateStringNode("100m")},
+		}
+	},
+	"grpc-status": func() *base.Schema {
+		minimum, maximum := 0.0, 16.0
+		return &base.Schema{
+			Title:       "grpc-status",
+			Description: "The numeric gRPC status code",
+			Type:        []string{"integer"},
+			Minimum:     &minimum,
+			Maximum:     &maximum,
+		}
+	},
+	"grpc-message": func() *base.Schema {
+		return &base.Schema{
+			Title:       "grpc-message",
+			Description: "The percent-encoded gRPC status message",
+			Type:        []string{"string"},
+		}
+	},
+}
@@ -1,10 +1,13 @@
 package connectrpc
 
 import (
+	"strings"
+
 	"github.com/pb33f/libopenapi/datamodel/high/base"
 	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
 	"github.com/pb33f/libopenapi/orderedmap"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/options"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/schema"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/util"
 	"google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/types/descriptorpb"
@@ -73,22 +76,29 @@ func MethodToOperation(opts options.Options, method protoreflect.MethodDescripto
 	}
 	if !opts.DisableDefaultResponse {
 		outputId := util.FormatTypeRef(string(method.Output().FullName()))
+		content := util.MakeMediaTypes(
+			opts,
+			base.CreateSchemaProxyRef("#/components/schemas/"+outputId),
+			false,
+			isStreaming,
+		)
+		if isStreaming {
+			setConnectStreamSchema(opts, content, base.CreateSchemaProxyRef("#/components/schemas/"+streamSchemaName(method)))
+		}
 		op.Responses.Codes.Set("200", &v3.Response{
 			Description: "Success",
-			Content: util.MakeMediaTypes(
-				opts,
-				base.CreateSchemaProxyRef("#/components/schemas/"+outputId),
-				false,
-				isStreaming,
-			),
+			Content:     content,
 		})
 	}
 
+	if opts.WithErrorResponses && !isStreaming {
+		addErrorResponses(opts, method, op.Responses)
+	}
 	op.Responses.Default = &v3.Response{
 		Description: "Error",
 		Content: util.MakeMediaTypes(
 			opts,
-			base.CreateSchemaProxyRef("#/components/schemas/connect.error"),
+			base.CreateSchemaProxyRef(errorRef(opts, method)),
 			false,
 			isStreaming,
 		),
@@ -107,9 +117,16 @@ func MethodToOperation(opts options.Options, method protoreflect.MethodDescripto
 		},
 	)
 
+	if opts.WithProtocolHeaders {
+		addProtocolHeaders(opts, method, op, returnGet)
+	}
+
 	// Request parameters
-	inputId := util.FormatTypeRef(string(method.Input().FullName()))
-	if returnGet {
+	inputRef := schema.MessageSchemaRef(schema.RequestOptions(opts, method), method.Input())
+	if returnGet && opts.IsOpenAPI32() {
+		op.OperationId = op.OperationId + ".get"
+		op.Parameters = append(op.Parameters, getQueryString(opts, inputRef))
+	} else if returnGet {
 		op.OperationId = op.OperationId + ".get"
 		op.Parameters = append(op.Parameters,
 			&v3.Parameter{
@@ -117,7 +134,7 @@ func MethodToOperation(opts options.Options, method protoreflect.MethodDescripto
 				In:   "query",
 				Content: util.MakeMediaTypes(
 					opts,
-					base.CreateSchemaProxyRef("#/components/schemas/"+util.FormatTypeRef(inputId)),
+					base.CreateSchemaProxyRef(inputRef),
 					true,
 					isStreaming),
 			},
@@ -147,7 +164,7 @@ func MethodToOperation(opts options.Options, method protoreflect.MethodDescripto
 		op.RequestBody = &v3.RequestBody{
 			Content: util.MakeMediaTypes(
 				opts,
-				base.CreateSchemaProxyRef("#/components/schemas/"+inputId),
+				base.CreateSchemaProxyRef(inputRef),
 				true,
 				isStreaming,
 			),
@@ -157,3 +174,51 @@ func MethodToOperation(opts options.Options, method protoreflect.MethodDescripto
 
 	return op
 }
+
+// setConnectStreamSchema replaces the schema of the Connect streaming media types with the schema of the
+// messages of the stream, which includes the end-stream message.
+func setConnectStreamSchema(opts options.Options, content *orderedmap.Map[string, *v3.MediaType], s *base.SchemaProxy) {
+	for pair := content.First(); pair != nil; pair = pair.Next() {
+		if !strings.HasPrefix(pair.Key(), "application/connect+") {
+			continue
+		}
+		if opts.IsOpenAPI32() {
+			pair.Value().ItemSchema = s
+		} else {
+			pair.Value().Schema = s
+		}
+	}
+}
+
+// getQueryString describes the whole query string of a Connect GET request as a single OpenAPI 3.2
+// `querystring` parameter, so the message can be described along with the parameters that say how it's encoded.
+func getQueryString(opts options.Options, inputRef string) *v3.Parameter {
+	message := &base.Schema{
+		Type:        []string{"string"},
+		Description: "The request message, encoded as described by the other query parameters.",
+	}
+	if _, ok := opts.ContentTypes["json"]; ok {
+		message.ContentMediaType = "application/json"
+		message.ContentSchema = base.CreateSchemaProxyRef(inputRef)
+	}
+	props := orderedmap.New[string, *base.SchemaProxy]()
+	props.Set("message", base.CreateSchemaProxy(message))
+	props.Set("encoding", base.CreateSchemaProxyRef("#/components/schemas/encoding"))
+	props.Set("base64", base.CreateSchemaProxyRef("#/components/schemas/base64"))
+	props.Set("compression", base.CreateSchemaProxyRef("#/components/schemas/compression"))
+	props.Set("connect", base.CreateSchemaProxyRef("#/components/schemas/connect"))
+	content := orderedmap.New[string, *v3.MediaType]()
+	content.Set("application/x-www-form-urlencoded", &v3.MediaType{
+		Schema: base.CreateSchemaProxy(&base.Schema{
+			Type:       []string{"object"},
+			Required:   []string{"message", "encoding"},
+			Properties: props,
+		}),
+	})
+	return &v3.Parameter{
+		Name:     "query",
+		In:       "querystring",
+		Required: util.BoolPtr(true),
+		Content:  content,
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/lmittmann/tint"
 	"github.com/pb33f/libopenapi"
@@ -22,6 +23,7 @@ import (
 	"google.golang.org/protobuf/types/dynamicpb"
 	pluginpb "google.golang.org/protobuf/types/pluginpb"
 
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/extensions"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/gnostic"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/options"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/util"
@@ -76,6 +78,26 @@ func ConvertWithOptions(req *pluginpb.CodeGeneratorRequest, opts options.Options
 		opts.FieldReferenceAnnotator = annotator
 	}
 
+	generate := generateFiles
+	if len(opts.VisibilityAudiences) > 0 {
+		generate = generateAudienceFiles
+	}
+	files, err := generate(req, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	features := uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL | pluginpb.CodeGeneratorResponse_FEATURE_SUPPORTS_EDITIONS)
+	return &pluginpb.CodeGeneratorResponse{
+		SupportedFeatures: &features,
+		MinimumEdition:    proto.Int32(int32(descriptorpb.Edition_EDITION_PROTO2)),
+		MaximumEdition:    proto.Int32(int32(descriptorpb.Edition_EDITION_2024)),
+		File:              files,
+	}, nil
+}
+
+// generateFiles generates the OpenAPI files for the files to generate of the request.
+func generateFiles(req *pluginpb.CodeGeneratorRequest, opts options.Options) ([]*pluginpb.CodeGeneratorResponse_File, error) {
 	files := []*pluginpb.CodeGeneratorResponse_File{}
 	genFiles := make(map[string]struct{}, len(req.FileToGenerate))
 	for _, file := range req.FileToGenerate {
@@ -91,6 +113,8 @@ func ConvertWithOptions(req *pluginpb.CodeGeneratorRequest, opts options.Options
 	}
 
 	opts.ExtensionTypeResolver = dynamicpb.NewTypes(resolver)
+	opts.Files = resolver
+	opts.FieldBehaviorCache = &sync.Map{}
 
 	newSpec := func() (*v3.Document, error) {
 		model := &v3.Document{}
@@ -118,6 +142,11 @@ func ConvertWithOptions(req *pluginpb.CodeGeneratorRequest, opts options.Options
 		return nil, err
 	}
 
+	configComponents, err := getConfigComponents(opts)
+	if err != nil {
+		return nil, err
+	}
+
 	spec, err := newSpec()
 	if err != nil {
 		return nil, err
@@ -164,6 +193,9 @@ func ConvertWithOptions(req *pluginpb.CodeGeneratorRequest, opts options.Options
 		}
 
 		spec.Tags = mergeTags(spec.Tags)
+		if configComponents != nil {
+			util.AppendComponents(spec, configComponents)
+		}
 		if overrideComponents != nil {
 			util.AppendComponents(spec, overrideComponents)
 		}
@@ -176,6 +208,10 @@ func ConvertWithOptions(req *pluginpb.CodeGeneratorRequest, opts options.Options
 	for path, spec := range outFiles {
 		path := path
 		spec := spec
+		pruneComponents(opts, spec)
+		if !opts.IsOpenAPI32() {
+			additionalOperationsToExtensions(opts, spec)
+		}
 		content, err := specToFile(opts, spec)
 		if err != nil {
 			return nil, err
@@ -186,14 +222,7 @@ func ConvertWithOptions(req *pluginpb.CodeGeneratorRequest, opts options.Options
 			GeneratedCodeInfo: &descriptorpb.GeneratedCodeInfo{},
 		})
 	}
-
-	features := uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL | pluginpb.CodeGeneratorResponse_FEATURE_SUPPORTS_EDITIONS)
-	return &pluginpb.CodeGeneratorResponse{
-		SupportedFeatures: &features,
-		MinimumEdition:    proto.Int32(int32(descriptorpb.Edition_EDITION_PROTO2)),
-		MaximumEdition:    proto.Int32(int32(descriptorpb.Edition_EDITION_2024)),
-		File:              files,
-	}, nil
+	return files, nil
 }
 
 func getOverrideComponents(opts options.Options) (*v3.Components, error) {
@@ -232,6 +261,14 @@ func mergeTags(tags []*base.Tag) []*base.Tag {
 			found[tag.Name].Description = tag.Description
 		}
 
+		// set the hierarchy if not already set
+		if found[tag.Name].Parent == "" && tag.Parent != "" {
+			found[tag.Name].Parent = tag.Parent
+		}
+		if found[tag.Name].Kind == "" && tag.Kind != "" {
+			found[tag.Name].Kind = tag.Kind
+		}
+
 		// set external docs if not already set
 		if found[tag.Name].ExternalDocs == nil && tag.ExternalDocs != nil {
 			found[tag.Name].ExternalDocs = tag.ExternalDocs
@@ -246,6 +283,34 @@ func mergeTags(tags []*base.Tag) []*base.Tag {
 	return res
 }
 
+// additionalOperationsToExtensions moves operations that OpenAPI 3.1 has no field for into the
+// `x-additional-operations` extension of their path item, keyed by HTTP method.
+func additionalOperationsToExtensions(opts options.Options, spec *v3.Document) {
+	for pathItem := range spec.Paths.PathItems.ValuesFromOldest() {
+		ops := orderedmap.New[string, *v3.Operation]()
+		if pathItem.Query != nil {
+			ops.Set("QUERY", pathItem.Query)
+		}
+		for pair := pathItem.AdditionalOperations.First(); pair != nil; pair = pair.Next() {
+			ops.Set(pair.Key(), pair.Value())
+		}
+		if ops.Len() == 0 {
+			continue
+		}
+		node := &yaml.Node{}
+		if err := node.Encode(ops); err != nil {
+			opts.Logger.Warn("unable to encode additional operations", slog.Any("error", err))
+			continue
+		}
+		if pathItem.Extensions == nil {
+			pathItem.Extensions = orderedmap.New[string, *yaml.Node]()
+		}
+		pathItem.Extensions.Set("x-additional-operations", node)
+		pathItem.Query = nil
+		pathItem.AdditionalOperations = nil
+	}
+}
+
 func specToFile(opts options.Options, spec *v3.Document) (string, error) {
 	switch opts.Format {
 	case "yaml":
@@ -265,6 +330,7 @@ func appendToSpec(opts options.Options, spec *v3.Document, fd protoreflect.FileD
 	if opts.FeatureEnabled(options.FeatureGnostic) {
 		gnostic.SpecWithFileAnnotations(opts, spec, fd)
 	}
+	extensions.SpecWithFileOptions(opts, spec, fd)
 
 	components := &v3.Components{
 		Schemas:         orderedmap.New[string, *base.SchemaProxy](),
@@ -285,7 +351,7 @@ func appendToSpec(opts options.Options, spec *v3.Document, fd protoreflect.FileD
 		enums := fd.Enums()
 		for i := 0; i < enums.Len(); i++ {
 			enum := enums.Get(i)
-			if visibility.ShouldBeFiltered(visibility.GetVisibilityRule(enum), opts.AllowedVisibilities) {
+			if visibility.IsHidden(opts, enum) {
 				continue
 			}
 			AddEnumToSchema(opts, enum, spec)
@@ -295,7 +361,7 @@ func appendToSpec(opts options.Options, spec *v3.Document, fd protoreflect.FileD
 		messages := fd.Messages()
 		for i := 0; i < messages.Len(); i++ {
 			message := messages.Get(i)
-			if visibility.ShouldBeFiltered(visibility.GetVisibilityRule(message), opts.AllowedVisibilities) {
+			if visibility.IsHidden(opts, message) {
 				continue
 			}
 			AddMessageSchemas(opts, message, spec)
@@ -345,8 +411,7 @@ func appendServiceDocs(opts options.Options, spec *v3.Document, fd protoreflect.
 			continue
 		}
 		// Add visibility filtering for services
-		if visibility.ShouldBeFiltered(visibility.GetVisibilityRule(service), opts.AllowedVisibilities) {
-			opts.Logger.Debug("Filtering service due to visibility", slog.String("service", string(service.FullName())), slog.Any("restriction_selectors", opts.AllowedVisibilities))
+		if visibility.IsHidden(opts, service) {
 			continue
 		}
 
@@ -367,6 +432,9 @@ func appendServiceDocs(opts options.Options, spec *v3.Document, fd protoreflect.
 
 func initializeDoc(opts options.Options, doc *v3.Document) {
 	opts.Logger.Debug("initializeDoc")
+	if opts.OpenAPIVersion != "" {
+		doc.Version = opts.OpenAPIVersion
+	}
 	if doc.Version == "" {
 		doc.Version = "3.1.0"
 	}
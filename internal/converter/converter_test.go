@@ -39,10 +39,29 @@ var scenarios = []Scenario{
 	{Name: "with_service_filters", Options: "services=**.User*"},
 	{Name: "with_google_error_detail", Options: "with-google-error-detail"},
 	{Name: "with_google_error_detail_googleapi", Options: "features=google.api.http;gnostic;protovalidate,with-google-error-detail"},
+	{Name: "connect_streaming", Options: "with-streaming"},
 	{Name: "twirp", Options: "features=google.api.http;twirp;gnostic;protovalidate"},
 	{Name: "twirp_only", Options: "features=twirp"},
+	{Name: "twirp_options", Options: "features=twirp,twirp-prefix=/rpc,path-prefix=/api,content-types=json;proto,with-error-responses,with-streaming,short-operation-ids,short-service-tags"},
 	{Name: "visibility", Options: "features=google.api.http;gnostic;protovalidate,allowed-visibilities=INTERNAL;PREVIEW"},
 	{Name: "disable_default_response", Options: "disable-default-response"},
+	{Name: "input_schemas", Options: "emit-unpopulated,use-enum-numbers"},
+	{Name: "request_schemas", Options: "with-request-schemas"},
+	{Name: "resources"},
+	{Name: "custom_methods"},
+	{Name: "openapi_32", Options: "openapi-version=3.2,rest-stream-formats=ndjson;sse,allow-get,with-streaming"},
+	{Name: "http_body"},
+	{Name: "rest_streaming", Options: "rest-stream-formats=ndjson;sse"},
+	{Name: "error_responses", Options: "with-error-responses,config=testdata/error_responses/config.yaml"},
+	{Name: "error_details", Options: "with-error-responses,config=testdata/error_details/config.yaml"},
+	{Name: "protocol_headers", Options: "with-protocol-headers,allow-get,with-streaming,content-types=json;proto;grpc"},
+	{Name: "custom_headers", Options: "features=connectrpc;google.api.http;twirp,config=testdata/custom_headers/config.yaml"},
+	{Name: "security", Options: "config=testdata/security/config.yaml"},
+	{Name: "extensions", Options: "config=testdata/extensions/config.yaml"},
+	{Name: "cel_rules"},
+	{Name: "protovalidate_extension", Options: "with-protovalidate-extension"},
+	{Name: "pgv", Options: "features=connectrpc;pgv"},
+	{Name: "trim_unused_components", Options: "base=testdata/trim_unused_components/base.yaml,trim-unused-types"},
 }
 
 type Scenario struct {
@@ -160,6 +179,19 @@ func validateOpenAPISpec(t *testing.T, protofile string, spec string) {
 			require.NoError(tt, err)
 
 			ok, errs := validate.ValidateHttpRequest(req)
+			// Cases with a response status validate the response to the request instead.
+			if testCase.Response.Status != 0 {
+				resp := &http.Response{
+					StatusCode: testCase.Response.Status,
+					Header:     http.Header{},
+					Body:       io.NopCloser(strings.NewReader(testCase.Response.Body)),
+					Request:    req,
+				}
+				for k, v := range testCase.Response.Headers {
+					resp.Header.Add(k, v)
+				}
+				ok, errs = validate.ValidateHttpResponse(req, resp)
+			}
 			require.Len(tt, errs, len(testCase.Errors), "Incorrect number of errors: %+v", errs)
 
 			for i, err := range errs {
@@ -210,6 +242,14 @@ type TestCase struct {
 	Body    string            `yaml:"body"`
 	Query   string            `yaml:"query"`
 	Errors  []string          `yaml:"errors"`
+	// Response is validated against the operation of the request when its status is set.
+	Response TestCaseResponse `yaml:"response"`
+}
+
+type TestCaseResponse struct {
+	Status  int               `yaml:"status"`
+	Headers map[string]string `yaml:"headers"`
+	Body    string            `yaml:"body"`
 }
 
 func makeOutputPath(protofile, format string) string {
@@ -0,0 +1,144 @@
+// Package extensions maps custom proto options to OpenAPI specification extensions, as declared in the
+// `extensions` of the config file.
+package extensions
+
+import (
+	"encoding/json"
+	"log/slog"
+
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+	"github.com/pb33f/libopenapi/orderedmap"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/options"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/util"
+	"go.yaml.in/yaml/v4"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// SpecWithFileOptions adds the extensions for the options of a file to the document.
+func SpecWithFileOptions(opts options.Options, spec *v3.Document, fd protoreflect.FileDescriptor) {
+	spec.Extensions = appendExtensions(opts, spec.Extensions, fd)
+}
+
+// TagWithServiceOptions adds the extensions for the options of a service to its tag.
+func TagWithServiceOptions(opts options.Options, tag *base.Tag, sd protoreflect.ServiceDescriptor) {
+	tag.Extensions = appendExtensions(opts, tag.Extensions, sd)
+}
+
+// PathItemWithMethodOptions adds the extensions for the options of a method to the operations of the path item.
+func PathItemWithMethodOptions(opts options.Options, item *v3.PathItem, md protoreflect.MethodDescriptor) {
+	values := optionValues(opts, md)
+	if orderedmap.Len(values) == 0 {
+		return
+	}
+	for op := range util.PathItemOperations(item).ValuesFromOldest() {
+		op.Extensions = merge(op.Extensions, values)
+	}
+}
+
+// SchemaWithMessageOptions adds the extensions for the options of a message to its schema.
+func SchemaWithMessageOptions(opts options.Options, schema *base.Schema, md protoreflect.MessageDescriptor) *base.Schema {
+	schema.Extensions = appendExtensions(opts, schema.Extensions, md)
+	return schema
+}
+
+// SchemaWithFieldOptions adds the extensions for the options of a field to its schema.
+func SchemaWithFieldOptions(opts options.Options, schema *base.Schema, fd protoreflect.FieldDescriptor) *base.Schema {
+	schema.Extensions = appendExtensions(opts, schema.Extensions, fd)
+	return schema
+}
+
+// SchemaWithEnumOptions adds the extensions for the options of an enum to its schema. Options of enum values
+// become extensions that map the names of the values to the values of the option.
+func SchemaWithEnumOptions(opts options.Options, schema *base.Schema, ed protoreflect.EnumDescriptor) *base.Schema {
+	schema.Extensions = appendExtensions(opts, schema.Extensions, ed)
+	byValue := orderedmap.New[string, *yaml.Node]()
+	values := ed.Values()
+	for i := 0; i < values.Len(); i++ {
+		value := values.Get(i)
+		for pair := optionValues(opts, value).First(); pair != nil; pair = pair.Next() {
+			node, ok := byValue.Get(pair.Key())
+			if !ok {
+				node = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+				byValue.Set(pair.Key(), node)
+			}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: string(value.Name())}, pair.Value())
+		}
+	}
+	schema.Extensions = merge(schema.Extensions, byValue)
+	return schema
+}
+
+func appendExtensions(opts options.Options, extensions *orderedmap.Map[string, *yaml.Node], desc protoreflect.Descriptor) *orderedmap.Map[string, *yaml.Node] {
+	return merge(extensions, optionValues(opts, desc))
+}
+
+func merge(extensions, values *orderedmap.Map[string, *yaml.Node]) *orderedmap.Map[string, *yaml.Node] {
+	if orderedmap.Len(values) == 0 {
+		return extensions
+	}
+	if extensions == nil {
+		extensions = orderedmap.New[string, *yaml.Node]()
+	}
+	for pair := values.First(); pair != nil; pair = pair.Next() {
+		extensions.Set(pair.Key(), pair.Value())
+	}
+	return extensions
+}
+
+// optionValues returns the values of the mapped options that are set on the descriptor, keyed by the names of
+// their extensions. Values are rendered the way protojson renders them.
+func optionValues(opts options.Options, desc protoreflect.Descriptor) *orderedmap.Map[string, *yaml.Node] {
+	if opts.Config == nil || len(opts.Config.Extensions) == 0 {
+		return nil
+	}
+	descOptions := desc.Options()
+	if descOptions == nil || !descOptions.ProtoReflect().IsValid() {
+		return nil
+	}
+
+	// Custom options that aren't linked into the plugin are unknown fields, so they're parsed again with the
+	// extension types of the input files.
+	b, err := proto.Marshal(descOptions)
+	if err != nil || len(b) == 0 {
+		return nil
+	}
+	parsed := descOptions.ProtoReflect().New().Interface()
+	if err := (proto.UnmarshalOptions{Resolver: opts.GetExtensionTypeResolver()}).Unmarshal(b, parsed); err != nil {
+		opts.Logger.Warn("unable to parse options", slog.String("descriptor", string(desc.FullName())), slog.Any("error", err))
+		return nil
+	}
+	marshalOptions := protojson.MarshalOptions{}
+	if resolver, ok := opts.GetExtensionTypeResolver().(interface {
+		protoregistry.ExtensionTypeResolver
+		protoregistry.MessageTypeResolver
+	}); ok {
+		marshalOptions.Resolver = resolver
+	}
+	j, err := marshalOptions.Marshal(parsed)
+	if err != nil {
+		opts.Logger.Warn("unable to render options", slog.String("descriptor", string(desc.FullName())), slog.Any("error", err))
+		return nil
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(j, &fields); err != nil {
+		return nil
+	}
+
+	values := orderedmap.New[string, *yaml.Node]()
+	for _, mapping := range opts.Config.Extensions {
+		raw, ok := fields["["+mapping.Option+"]"]
+		if !ok {
+			continue
+		}
+		var node yaml.Node
+		if err := yaml.Unmarshal(raw, &node); err != nil || len(node.Content) == 0 {
+			continue
+		}
+		values.Set(mapping.Name, util.ResetNodeStyle(node.Content[0]))
+	}
+	return values
+}
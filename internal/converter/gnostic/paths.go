@@ -19,7 +19,7 @@ func PathItemWithMethodAnnotations(opts options.Options, item *v3.PathItem, md p
 	if !ok {
 		return item
 	}
-	operations := item.GetOperations()
+	operations := util.PathItemOperations(item)
 	for kv := operations.First(); kv != nil; kv = kv.Next() {
 		oper := kv.Value()
 		if gnosticOperation.Deprecated {
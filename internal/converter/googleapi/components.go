@@ -1,18 +1,36 @@
 package googleapi
 
 import (
+	"slices"
+	"strconv"
+
 	"github.com/pb33f/libopenapi/datamodel/high/base"
 	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
 	"github.com/pb33f/libopenapi/orderedmap"
+	"github.com/pb33f/libopenapi/utils"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/options"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/schema"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/util"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 // AddSchemas adds google.rpc.Status and google.protobuf.Any schemas to the
 // OpenAPI document components when WithGoogleErrorDetail is enabled. This gives
 // REST client generators typed error handling for google.api.http-annotated
-// methods.
-func AddSchemas(opts options.Options, doc *v3.Document) {
-	if !opts.WithGoogleErrorDetail {
+// methods. Server-streaming methods also get the schema of their stream messages,
+// which embed google.rpc.Status.
+func AddSchemas(opts options.Options, doc *v3.Document, md protoreflect.MethodDescriptor) {
+	if md.IsStreamingServer() {
+		name := streamSchemaName(md)
+		// Methods with a response_body stream the field instead, which is wrapped inline.
+		rule, _ := proto.GetExtension(md.Options(), annotations.E_Http).(*annotations.HttpRule)
+		if _, ok := doc.Components.Schemas.Get(name); !ok && rule.GetResponseBody() == "" {
+			result := base.CreateSchemaProxyRef("#/components/schemas/" + util.FormatTypeRef(string(md.Output().FullName())))
+			doc.Components.Schemas.Set(name, base.CreateSchemaProxy(streamChunkSchema(result)))
+		}
+	} else if !opts.WithGoogleErrorDetail && !hasMethodStatusSchema(opts, md) {
 		return
 	}
 	components := doc.Components
@@ -32,28 +50,148 @@ func AddSchemas(opts options.Options, doc *v3.Document) {
 	}
 
 	if _, ok := components.Schemas.Get("google.rpc.Status"); !ok {
-		statusProps := orderedmap.New[string, *base.SchemaProxy]()
-		statusProps.Set("code", base.CreateSchemaProxy(&base.Schema{
-			Type:        []string{"integer"},
-			Format:      "int32",
-			Description: "The status code, which should be an enum value of google.rpc.Code.",
-		}))
-		statusProps.Set("message", base.CreateSchemaProxy(&base.Schema{
-			Type:        []string{"string"},
-			Description: "A developer-facing error message.",
-		}))
-		statusProps.Set("details", base.CreateSchemaProxy(&base.Schema{
-			Type:        []string{"array"},
-			Description: "A list of messages that carry the error details.",
-			Items: &base.DynamicValue[*base.SchemaProxy, bool]{
-				N: 0,
-				A: base.CreateSchemaProxyRef("#/components/schemas/google.protobuf.Any"),
-			},
-		}))
-		components.Schemas.Set("google.rpc.Status", base.CreateSchemaProxy(&base.Schema{
-			Type:        []string{"object"},
-			Description: "The Status type defines a logical error model suitable for gRPC and REST APIs.",
-			Properties:  statusProps,
-		}))
+		components.Schemas.Set("google.rpc.Status", base.CreateSchemaProxy(newStatusSchema(nil, base.CreateSchemaProxyRef("#/components/schemas/google.protobuf.Any"))))
+	}
+	addMethodStatusSchema(opts, components, md)
+}
+
+// rpcCodes are the google.rpc.Code values, starting at CANCELLED = 1, in the spelling of the Connect error
+// codes that the config uses.
+var rpcCodes = []string{
+	"canceled",
+	"unknown",
+	"invalid_argument",
+	"deadline_exceeded",
+	"not_found",
+	"already_exists",
+	"permission_denied",
+	"resource_exhausted",
+	"failed_precondition",
+	"aborted",
+	"out_of_range",
+	"unimplemented",
+	"internal",
+	"unavailable",
+	"data_loss",
+	"unauthenticated",
+}
+
+// newStatusSchema returns a google.rpc.Status schema. When codes are given, `code` only allows their
+// google.rpc.Code values.
+func newStatusSchema(codes []string, detailsItem *base.SchemaProxy) *base.Schema {
+	statusProps := orderedmap.New[string, *base.SchemaProxy]()
+	code := &base.Schema{
+		Type:        []string{"integer"},
+		Format:      "int32",
+		Description: "The status code, which should be an enum value of google.rpc.Code.",
+	}
+	for i, name := range rpcCodes {
+		if slices.Contains(codes, name) {
+			code.Enum = append(code.Enum, utils.CreateIntNode(strconv.Itoa(i+1)))
+		}
+	}
+	statusProps.Set("code", base.CreateSchemaProxy(code))
+	statusProps.Set("message", base.CreateSchemaProxy(&base.Schema{
+		Type:        []string{"string"},
+		Description: "A developer-facing error message.",
+	}))
+	statusProps.Set("details", base.CreateSchemaProxy(&base.Schema{
+		Type:        []string{"array"},
+		Description: "A list of messages that carry the error details.",
+		Items: &base.DynamicValue[*base.SchemaProxy, bool]{
+			N: 0,
+			A: detailsItem,
+		},
+	}))
+	return &base.Schema{
+		Type:        []string{"object"},
+		Description: "The Status type defines a logical error model suitable for gRPC and REST APIs.",
+		Properties:  statusProps,
+	}
+}
+
+// hasMethodStatusSchema reports whether the error codes or details of the method are declared in the config, in
+// which case the method gets its own google.rpc.Status schema.
+func hasMethodStatusSchema(opts options.Options, md protoreflect.MethodDescriptor) bool {
+	rule := opts.Config.ErrorResponseRule(md.FullName())
+	return rule != nil && (len(rule.Codes) > 0 || len(rule.Details) > 0)
+}
+
+// statusRef returns the reference to the schema of the errors of the method.
+func statusRef(opts options.Options, md protoreflect.MethodDescriptor) string {
+	if hasMethodStatusSchema(opts, md) {
+		return "#/components/schemas/" + util.FormatTypeRef(string(md.FullName())) + ".google.rpc.Status"
+	}
+	return "#/components/schemas/google.rpc.Status"
+}
+
+// addMethodStatusSchema adds the google.rpc.Status schema of a method with declared errors. The `code` only
+// allows the declared codes and each detail is one of the declared messages in the JSON form of
+// google.protobuf.Any, with the type URL in `@type`.
+func addMethodStatusSchema(opts options.Options, components *v3.Components, md protoreflect.MethodDescriptor) {
+	if !hasMethodStatusSchema(opts, md) {
+		return
+	}
+	name := util.FormatTypeRef(string(md.FullName())) + ".google.rpc.Status"
+	if _, ok := components.Schemas.Get(name); ok {
+		return
+	}
+	detailsItem := base.CreateSchemaProxyRef("#/components/schemas/google.protobuf.Any")
+	if details := opts.Config.ErrorDetails(md.FullName()); len(details) > 0 {
+		oneOf := make([]*base.SchemaProxy, 0, len(details))
+		for _, detail := range details {
+			oneOf = append(oneOf, base.CreateSchemaProxy(newDetailSchema(opts, detail)))
+		}
+		detailsItem = base.CreateSchemaProxy(&base.Schema{OneOf: oneOf})
+	}
+	codes, _ := opts.Config.ErrorCodes(md.FullName())
+	components.Schemas.Set(name, base.CreateSchemaProxy(newStatusSchema(codes, detailsItem)))
+}
+
+// newDetailSchema returns the schema of an error detail in the JSON form of google.protobuf.Any. Message schemas
+// don't allow additional properties, so the schema of a message in the request files is inlined with `@type`
+// added to its properties. Well-known types keep their JSON value in `value`.
+func newDetailSchema(opts options.Options, detail string) *base.Schema {
+	typeURL := base.CreateSchemaProxy(&base.Schema{
+		Type:  []string{"string"},
+		Const: utils.CreateStringNode("type.googleapis.com/" + detail),
+	})
+	var md protoreflect.MessageDescriptor
+	if opts.Files != nil {
+		if desc, err := opts.Files.FindDescriptorByName(protoreflect.FullName(detail)); err == nil {
+			md, _ = desc.(protoreflect.MessageDescriptor)
+		}
+	}
+	switch {
+	case md != nil && util.IsWellKnown(md):
+		props := orderedmap.New[string, *base.SchemaProxy]()
+		props.Set("@type", typeURL)
+		props.Set("value", base.CreateSchemaProxyRef(schema.MessageSchemaRef(opts, md)))
+		return &base.Schema{
+			Type:       []string{"object"},
+			Properties: props,
+			Required:   []string{"@type", "value"},
+		}
+	case md != nil:
+		_, s := schema.MessageToSchema(opts, md)
+		if s.Properties == nil {
+			s.Properties = orderedmap.New[string, *base.SchemaProxy]()
+		}
+		s.Properties.Set("@type", typeURL)
+		s.Required = append(s.Required, "@type")
+		return s
+	}
+	// The schemas of the google.rpc error details allow additional properties.
+	typeProps := orderedmap.New[string, *base.SchemaProxy]()
+	typeProps.Set("@type", typeURL)
+	return &base.Schema{
+		AllOf: []*base.SchemaProxy{
+			base.CreateSchemaProxyRef(schema.MessageRefByName(opts, detail)),
+			base.CreateSchemaProxy(&base.Schema{
+				Type:       []string{"object"},
+				Properties: typeProps,
+				Required:   []string{"@type"},
+			}),
+		},
 	}
 }
@@ -0,0 +1,71 @@
+package googleapi
+
+import (
+	"log/slog"
+	"mime"
+	"regexp"
+	"strings"
+
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+	"github.com/pb33f/libopenapi/orderedmap"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/options"
+)
+
+const httpBodyFullName protoreflect.FullName = "google.api.HttpBody"
+
+// contentTypeDirective matches comment directives that declare the media types of HttpBody bodies, like
+// `(-- response-content-type: text/csv --)`. Comments in `(-- --)` are left out of the descriptions.
+var contentTypeDirective = regexp.MustCompile(`\(--\s*(request|response)-content-types?:\s*(.*?)\s*--\)`)
+
+// isHTTPBody reports whether the message is google.api.HttpBody, which is sent as the raw HTTP body.
+func isHTTPBody(md protoreflect.MessageDescriptor) bool {
+	return md != nil && md.FullName() == httpBodyFullName
+}
+
+// isHTTPBodyField reports whether the field is a singular google.api.HttpBody field.
+func isHTTPBodyField(field protoreflect.FieldDescriptor) bool {
+	return field != nil && field.Kind() == protoreflect.MessageKind && !field.IsList() && !field.IsMap() && isHTTPBody(field.Message())
+}
+
+// httpBodyContentTypes returns the media types of the request or response HttpBody of the method. Methods can
+// declare them with a comment directive, otherwise the http-body-content-types option is used.
+func httpBodyContentTypes(opts options.Options, md protoreflect.MethodDescriptor, direction string) []string {
+	loc := md.ParentFile().SourceLocations().ByDescriptor(md)
+	contentTypes := []string{}
+	for _, comments := range []string{loc.LeadingComments, loc.TrailingComments} {
+		for _, match := range contentTypeDirective.FindAllStringSubmatch(comments, -1) {
+			if match[1] != direction {
+				continue
+			}
+			for _, contentType := range strings.Split(match[2], ",") {
+				contentType = strings.TrimSpace(contentType)
+				if _, _, err := mime.ParseMediaType(contentType); err != nil {
+					opts.Logger.Warn("invalid content type in comment directive", slog.String("method", string(md.FullName())), slog.String("content-type", contentType))
+					continue
+				}
+				contentTypes = append(contentTypes, contentType)
+			}
+		}
+	}
+	if len(contentTypes) > 0 {
+		return contentTypes
+	}
+	if len(opts.HTTPBodyContentTypes) > 0 {
+		return opts.HTTPBodyContentTypes
+	}
+	return []string{"*/*"}
+}
+
+// httpBodyMediaTypes creates the media types of a raw HttpBody body.
+func httpBodyMediaTypes(contentTypes []string) *orderedmap.Map[string, *v3.MediaType] {
+	mediaTypes := orderedmap.New[string, *v3.MediaType]()
+	for _, contentType := range contentTypes {
+		mediaTypes.Set(contentType, &v3.MediaType{
+			Schema: base.CreateSchemaProxy(&base.Schema{Type: []string{"string"}, Format: "binary"}),
+		})
+	}
+	return mediaTypes
+}
@@ -5,7 +5,6 @@ import (
 	"iter"
 	"log/slog"
 	"net/http"
-	"regexp"
 	"slices"
 	"strings"
 
@@ -20,11 +19,9 @@ import (
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/options"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/schema"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/util"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/visibility"
 )
 
-// namedPathPattern is a regular expression to match named path patterns in the form {name=path/*/pattern}
-var namedPathPattern = regexp.MustCompile("{(.+)=(.+)}")
-
 // PathItemsResult holds path items and any parameters whose default
 // descriptions should only be applied after all annotation processing
 // (e.g. gnostic) has had a chance to set descriptions first.
@@ -76,7 +73,7 @@ func httpRuleToPathMap(opts options.Options, md protoreflect.MethodDescriptor, r
 		return nil
 	}
 
-	tokens, err := RunPathPatternLexer(template)
+	tmpl, err := ParsePathTemplate(template)
 	if err != nil {
 		opts.Logger.Warn("unable to parse template pattern", slog.Any("error", err), slog.String("template", template))
 		return nil
@@ -103,6 +100,9 @@ func httpRuleToPathMap(opts options.Options, md protoreflect.MethodDescriptor, r
 		Deprecated:  util.IsMethodDeprecated(md),
 	}
 
+	// Everything that makes up the request is described with request schemas
+	reqOpts := schema.RequestOptions(opts, md)
+
 	if !opts.WithoutDefaultTags {
 		tagName := string(service.FullName())
 		if opts.ShortServiceTags {
@@ -114,109 +114,108 @@ func httpRuleToPathMap(opts options.Options, md protoreflect.MethodDescriptor, r
 	fieldNamesInPath := map[string]struct{}{}
 	var pathParams []*v3.Parameter
 	var deferredParams []*v3.Parameter
-	for _, param := range partsToParameter(tokens) {
-		// Skip the name parameter if it's part of a glob pattern
-		if strings.Contains(param, "=") {
-			continue
-		}
-		field, jsonPath := resolveField(opts, md.Input(), param)
-		if field != nil {
-			// This field is only top level, so we will filter out the param from
-			// query/param or request body
-			fieldNamesInPath[string(field.FullName())] = struct{}{}
-			fieldNamesInPath[strings.Join(jsonPath, ".")] = struct{}{} // sometimes JSON field names are used
-			loc := fd.SourceLocations().ByDescriptor(field)
-			newParameter := &v3.Parameter{
-				Name:        param,
-				Required:    proto.Bool(true),
+	wildcards := 0
+	for _, segment := range tmpl.Segments {
+		switch segment.Kind {
+		case SegmentWildcard:
+			pathParams = append(pathParams, &v3.Parameter{
+				Name:        wildcardParameterName(wildcards),
 				In:          "path",
-				Description: util.FormatComments(loc),
-				Schema:      schema.FieldToSchema(opts, nil, field),
-			}
-			pathParams = append(pathParams, newParameter)
-		} else {
-			opts.Logger.Warn("path field not found", slog.String("param", param))
-		}
-	}
-
-	// Add named path parameters from glob patterns
-	for _, token := range tokens {
-		if token.Type == TokenLiteral && token.Value == "**" {
-			newParameter := &v3.Parameter{
+				Required:    proto.Bool(true),
+				Description: "A segment of the path.",
+				Schema:      base.CreateSchemaProxy(&base.Schema{Type: []string{"string"}, Pattern: "^[^/]+$"}),
+			})
+			wildcards++
+		case SegmentDeepWildcard:
+			pathParams = append(pathParams, &v3.Parameter{
 				Name:          "http_path",
 				In:            "path",
 				Required:      proto.Bool(true),
 				Description:   "The trailing part of the path.",
 				AllowReserved: true,
 				Schema:        base.CreateSchemaProxy(&base.Schema{Type: []string{"string"}}),
+			})
+		case SegmentVariable:
+			variable := segment.Variable
+			param := variable.FieldPath
+			field, jsonPath := resolveField(opts, md.Input(), param)
+			if field == nil {
+				opts.Logger.Warn("path field not found", slog.String("param", param))
 			}
-			pathParams = append(pathParams, newParameter)
-		}
-		if token.Type == TokenVariable && strings.Contains(token.Value, "=") {
-			matches := namedPathPattern.FindStringSubmatch("{" + token.Value + "}")
-			if len(matches) == 3 {
-				if matches[2] == "**" {
-					paramName := matches[1]
-					field, _ := resolveField(opts, md.Input(), paramName)
-					var newParameter *v3.Parameter
-					if field != nil {
-						fieldNamesInPath[string(field.FullName())] = struct{}{}
-						fieldNamesInPath[field.JSONName()] = struct{}{}
-						loc := fd.SourceLocations().ByDescriptor(field)
-						parameterSchema := schema.FieldToSchema(opts, nil, field)
-						// Path parameters must be primitives.
-						if slices.Contains(parameterSchema.Schema().Type, "object") || slices.Contains(parameterSchema.Schema().Type, "array") {
-							parameterSchema = base.CreateSchemaProxy(&base.Schema{Type: []string{"string"}})
-						}
-						newParameter = &v3.Parameter{
-							Name:          paramName,
-							Required:      proto.Bool(true),
-							In:            "path",
-							Description:   util.FormatComments(loc),
-							AllowReserved: true,
-							Schema:        parameterSchema,
-						}
-					} else {
-						newParameter = &v3.Parameter{
-							Name:          paramName,
-							Required:      proto.Bool(true),
-							In:            "path",
-							Description:   "The trailing part of the path.",
-							AllowReserved: true,
-							Schema:        base.CreateSchemaProxy(&base.Schema{Type: []string{"string"}}),
-						}
-					}
-					pathParams = append(pathParams, newParameter)
+			if variable.IsSingleWildcard() || isSingleDeepWildcard(variable) {
+				if field != nil && isHiddenFieldPath(opts, md.Input(), param) {
+					// The route still needs the parameter, but nothing about the hidden field is described.
+					fieldNamesInPath[string(field.FullName())] = struct{}{}
+					fieldNamesInPath[strings.Join(jsonPath, ".")] = struct{}{}
+					pathParams = append(pathParams, &v3.Parameter{
+						Name:          param,
+						Required:      proto.Bool(true),
+						In:            "path",
+						AllowReserved: variable.IsMultiSegment(),
+						Schema:        base.CreateSchemaProxy(&base.Schema{Type: []string{"string"}}),
+					})
 					continue
 				}
-				// Store the original field name from the glob pattern to prevent it from appearing
-				// in both the path parameters and request body/query parameters
-				originalName := matches[1]
-				fieldNamesInPath[originalName] = struct{}{}
-				// Convert the path from the starred form to use named path parameters.
-				// The starred path may be in the form "things/*/otherthings/*" or contain
-				// literal segments like "things/*/static/otherthings/*".
-				starredPath := matches[2]
-				parts := strings.Split(starredPath, "/")
-				for i, part := range parts {
-					if part != "*" || i == 0 {
+				if field == nil {
+					if variable.IsSingleWildcard() {
 						continue
 					}
-					section := parts[i-1]
-					namedPathParameter := util.Singular(section)
-					newParameter := &v3.Parameter{
-						Name:     namedPathParameter,
-						In:       "path",
-						Required: proto.Bool(true),
-						Schema:   base.CreateSchemaProxy(&base.Schema{Type: []string{"string"}}),
-					}
-					pathParams = append(pathParams, newParameter)
-					deferredParams = append(deferredParams, &v3.Parameter{
-						Name:        namedPathParameter,
-						In:          "path",
-						Description: "The " + namedPathParameter + " id.",
+					pathParams = append(pathParams, &v3.Parameter{
+						Name:          param,
+						Required:      proto.Bool(true),
+						In:            "path",
+						Description:   "The trailing part of the path.",
+						AllowReserved: true,
+						Schema:        base.CreateSchemaProxy(&base.Schema{Type: []string{"string"}}),
 					})
+					continue
+				}
+				// This field is only top level, so we will filter out the param from
+				// query/param or request body
+				fieldNamesInPath[string(field.FullName())] = struct{}{}
+				fieldNamesInPath[strings.Join(jsonPath, ".")] = struct{}{} // sometimes JSON field names are used
+				loc := fd.SourceLocations().ByDescriptor(field)
+				parameterSchema := schema.FieldToSchema(reqOpts, nil, field)
+				// Path parameters must be primitives.
+				if slices.Contains(parameterSchema.Schema().Type, "object") || slices.Contains(parameterSchema.Schema().Type, "array") {
+					parameterSchema = base.CreateSchemaProxy(&base.Schema{Type: []string{"string"}})
+				}
+				pathParams = append(pathParams, &v3.Parameter{
+					Name:          param,
+					Required:      proto.Bool(true),
+					In:            "path",
+					Description:   util.FormatComments(loc),
+					AllowReserved: variable.IsMultiSegment(),
+					Schema:        parameterSchema,
+				})
+				continue
+			}
+			// Store the original field name from the variable to prevent it from appearing
+			// in both the path parameters and request body/query parameters
+			fieldNamesInPath[param] = struct{}{}
+			if field != nil {
+				fieldNamesInPath[string(field.FullName())] = struct{}{}
+			}
+			// Variables that span multiple segments, like {name=things/*/otherthings/*}, are split into
+			// one path parameter for each wildcard segment.
+			segmentParams := namedPathParameters(opts, md.Input(), variable)
+			for i := range variable.Segments {
+				segmentParam, ok := segmentParams[i]
+				if !ok {
+					continue
 				}
+				pathParams = append(pathParams, &v3.Parameter{
+					Name:          segmentParam.Name,
+					In:            "path",
+					Required:      proto.Bool(true),
+					AllowReserved: segmentParam.AllowReserved,
+					Schema:        base.CreateSchemaProxy(&base.Schema{Type: []string{"string"}, Pattern: segmentParam.Pattern}),
+				})
+				deferredParams = append(deferredParams, &v3.Parameter{
+					Name:        segmentParam.Name,
+					In:          "path",
+					Description: segmentParam.Description,
+				})
 			}
 		}
 	}
@@ -233,13 +232,22 @@ func httpRuleToPathMap(opts options.Options, md protoreflect.MethodDescriptor, r
 	}
 
 	if !hasGnosticRequestBody {
-		switch rule.Body {
-		case "":
-			newQueryParams := flattenToParams(opts, md.Input(), "", fieldNamesInPath)
+		switch {
+		case visibility.IsHidden(opts, md.Input()):
+			// The request message is hidden, so neither its body nor its query parameters are described
+		case rule.Body == "" && isHTTPBody(md.Input()):
+			// The request is the raw HTTP body, which GET and DELETE methods don't have
+		case rule.Body == "*" && isHTTPBody(md.Input()):
+			op.RequestBody = &v3.RequestBody{
+				Content:  httpBodyMediaTypes(httpBodyContentTypes(opts, md, "request")),
+				Required: proto.Bool(true),
+			}
+		case rule.Body == "":
+			newQueryParams := flattenToParams(reqOpts, md.Input(), "", fieldNamesInPath)
 			op.Parameters = util.MergeParameters(op.Parameters, newQueryParams)
-		case "*":
+		case rule.Body == "*":
 			if len(fieldNamesInPath) > 0 {
-				_, s := schema.MessageToSchema(opts, md.Input())
+				_, s := schema.MessageToSchema(reqOpts, md.Input())
 				if s != nil {
 					// Remove path parameters from properties.
 					// When the message has oneOf fields, MessageToSchema wraps
@@ -273,18 +281,27 @@ func httpRuleToPathMap(opts options.Options, md protoreflect.MethodDescriptor, r
 					}
 				}
 			} else {
-				inputName := string(md.Input().FullName())
-				s := base.CreateSchemaProxyRef("#/components/schemas/" + util.FormatTypeRef(inputName))
+				s := base.CreateSchemaProxyRef(schema.MessageSchemaRef(reqOpts, md.Input()))
 				op.RequestBody = util.MethodToRequestBody(opts, md, s, false)
 			}
 
 		default:
 			if field, jsonPath := resolveField(opts, md.Input(), rule.Body); field != nil {
 				loc := fd.SourceLocations().ByDescriptor(field)
-				bodySchema := schema.FieldToSchema(opts, nil, field)
-				op.RequestBody = &v3.RequestBody{
-					Description: util.FormatComments(loc),
-					Content:     util.MakeMediaTypes(opts, bodySchema, false, false),
+				switch {
+				case isHiddenFieldPath(opts, md.Input(), rule.Body):
+					// The body is a hidden field, so it isn't described
+				case isHTTPBodyField(field):
+					op.RequestBody = &v3.RequestBody{
+						Description: util.FormatComments(loc),
+						Content:     httpBodyMediaTypes(httpBodyContentTypes(opts, md, "request")),
+					}
+				default:
+					bodySchema := schema.FieldToSchema(reqOpts, nil, field)
+					op.RequestBody = &v3.RequestBody{
+						Description: util.FormatComments(loc),
+						Content:     util.MakeMediaTypes(opts, bodySchema, false, false),
+					}
 				}
 
 				// Add any unhandled fields in the request message as query parameters.
@@ -302,7 +319,7 @@ func httpRuleToPathMap(opts options.Options, md protoreflect.MethodDescriptor, r
 				// If body is a nested path (a.b.c) also skip its JSON path
 				coveredFields[strings.Join(jsonPath, ".")] = struct{}{}
 
-				newQueryParams := flattenToParams(opts, md.Input(), "", coveredFields)
+				newQueryParams := flattenToParams(reqOpts, md.Input(), "", coveredFields)
 				op.Parameters = util.MergeParameters(op.Parameters, newQueryParams)
 			} else {
 				opts.Logger.Warn("body field not found", slog.String("param", rule.Body))
@@ -315,26 +332,42 @@ func httpRuleToPathMap(opts options.Options, md protoreflect.MethodDescriptor, r
 
 	if !opts.DisableDefaultResponse {
 		var outputSchema *base.SchemaProxy
+		isRawResponse := false
 		if rule.ResponseBody == "" {
 			outputSchema = base.CreateSchemaProxyRef("#/components/schemas/" + util.FormatTypeRef(string(md.Output().FullName())))
+			isRawResponse = isHTTPBody(md.Output())
 		} else {
-			if fd, _ := resolveField(opts, md.Output(), rule.ResponseBody); fd != nil {
+			if fd, _ := resolveField(opts, md.Output(), rule.ResponseBody); fd != nil && !isHiddenFieldPath(opts, md.Output(), rule.ResponseBody) {
 				outputSchema = schema.FieldToSchema(opts, nil, fd)
+				isRawResponse = isHTTPBodyField(fd)
 			}
 		}
 
 		mediaType := orderedmap.New[string, *v3.MediaType]()
-		mediaType.Set("application/json", &v3.MediaType{Schema: outputSchema})
+		description := "Success"
+		switch {
+		case md.IsStreamingServer():
+			description = "A stream of " + string(md.Output().Name()) + " messages"
+			chunk := base.CreateSchemaProxyRef("#/components/schemas/" + streamSchemaName(md))
+			if rule.ResponseBody != "" {
+				chunk = base.CreateSchemaProxy(streamChunkSchema(outputSchema))
+			}
+			mediaType = streamMediaTypes(opts, chunk)
+		case isRawResponse:
+			mediaType = httpBodyMediaTypes(httpBodyContentTypes(opts, md, "response"))
+		default:
+			mediaType.Set("application/json", &v3.MediaType{Schema: outputSchema})
+		}
 		codeMap.Set("200", &v3.Response{
-			Description: "Success",
+			Description: description,
 			Content:     mediaType,
 		})
 	}
 
-	if opts.WithGoogleErrorDetail {
+	if opts.WithGoogleErrorDetail || hasMethodStatusSchema(opts, md) {
 		errorMediaType := orderedmap.New[string, *v3.MediaType]()
 		errorMediaType.Set("application/json", &v3.MediaType{
-			Schema: base.CreateSchemaProxyRef("#/components/schemas/google.rpc.Status"),
+			Schema: base.CreateSchemaProxyRef(statusRef(opts, md)),
 		})
 		codeMap.Set("default", &v3.Response{
 			Description: "An unexpected error response.",
@@ -342,11 +375,17 @@ func httpRuleToPathMap(opts options.Options, md protoreflect.MethodDescriptor, r
 		})
 	}
 
+	// Responses to HEAD requests never have a body.
+	if strings.EqualFold(method, http.MethodHead) {
+		for response := range codeMap.ValuesFromOldest() {
+			response.Content = nil
+		}
+	}
 	op.Responses = &v3.Responses{
 		Codes: codeMap,
 	}
 
-	switch method {
+	switch strings.ToUpper(method) {
 	case http.MethodGet:
 		pathItem.Get = op
 	case http.MethodPut:
@@ -357,9 +396,29 @@ func httpRuleToPathMap(opts options.Options, md protoreflect.MethodDescriptor, r
 		pathItem.Delete = op
 	case http.MethodPatch:
 		pathItem.Patch = op
+	case http.MethodHead:
+		pathItem.Head = op
+	case http.MethodOptions:
+		pathItem.Options = op
+	case http.MethodTrace:
+		pathItem.Trace = op
+	case "QUERY":
+		if opts.IsOpenAPI32() {
+			pathItem.Query = op
+			break
+		}
+		fallthrough
 	default:
+		// Methods without a dedicated field are additional operations in OpenAPI 3.2. They are moved to an
+		// extension when rendering OpenAPI 3.1 documents.
+		if !opts.IsOpenAPI32() {
+			opts.Logger.Warn("HTTP method is not supported by OpenAPI 3.1, adding the operation to the x-additional-operations extension instead; use openapi-version=3.2 to generate additionalOperations",
+				slog.String("method", method), slog.String("rpc", string(md.FullName())))
+		}
+		pathItem.AdditionalOperations = orderedmap.New[string, *v3.Operation]()
+		pathItem.AdditionalOperations.Set(method, op)
 	}
-	openAPIPath := partsToOpenAPIPath(tokens)
+	openAPIPath := templateToOpenAPIPath(opts, md.Input(), tmpl)
 	paths.Set(openAPIPath, pathItem)
 
 	allDeferred := orderedmap.New[string, []*v3.Parameter]()
@@ -392,7 +451,7 @@ func httpRuleToPathMap(opts options.Options, md protoreflect.MethodDescriptor, r
 func dedupeOperations(id string, value iter.Seq[*v3.PathItem]) {
 	num := 0
 	for path := range value {
-		for op := range path.GetOperations().ValuesFromOldest() {
+		for op := range util.PathItemOperations(path).ValuesFromOldest() {
 			if op.OperationId == id {
 				num++
 				if num > 1 {
@@ -419,6 +478,22 @@ func resolveField(opts options.Options, md protoreflect.MessageDescriptor, param
 	return fd, jsonParts
 }
 
+// isHiddenFieldPath reports whether any field along the path is hidden by its visibility rule.
+func isHiddenFieldPath(opts options.Options, md protoreflect.MessageDescriptor, param string) bool {
+	current := md
+	for _, paramPart := range strings.Split(param, ".") {
+		field := fieldByName(opts, current, paramPart)
+		if field == nil {
+			return false
+		}
+		if visibility.IsHidden(opts, field) {
+			return true
+		}
+		current = field.Message()
+	}
+	return false
+}
+
 func fieldByName(opts options.Options, md protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
 	fields := md.Fields()
 	if field := fields.ByName(protoreflect.Name(name)); field != nil {
@@ -430,77 +505,51 @@ func fieldByName(opts options.Options, md protoreflect.MessageDescriptor, name s
 	return nil
 }
 
-func partsToParameter(tokens []Token) []string {
-	params := []string{}
-	for _, token := range tokens {
-		if token.Type == TokenVariable {
-			// Skip parameters that contain = as they are part of a glob pattern
-			if strings.Contains(token.Value, "=") {
+// templateToOpenAPIPath converts a path template to an OpenAPI path. Variables that span multiple segments are
+// split into one path parameter for each wildcard segment and wildcards outside of variables get a generated name.
+func templateToOpenAPIPath(opts options.Options, md protoreflect.MessageDescriptor, tmpl *PathTemplate) string {
+	parts := make([]string, 0, len(tmpl.Segments))
+	wildcards := 0
+	for _, segment := range tmpl.Segments {
+		switch segment.Kind {
+		case SegmentWildcard:
+			parts = append(parts, "{"+wildcardParameterName(wildcards)+"}")
+			wildcards++
+		case SegmentDeepWildcard:
+			parts = append(parts, "{http_path}")
+		case SegmentVariable:
+			variable := segment.Variable
+			if variable.IsSingleWildcard() || isSingleDeepWildcard(variable) {
+				parts = append(parts, "{"+variable.FieldPath+"}")
 				continue
 			}
-			params = append(params, strings.SplitN(token.Value, "=", 2)[0])
-		}
-	}
-	return params
-}
-
-func partsToOpenAPIPath(tokens []Token) string {
-	var b strings.Builder
-	for _, token := range tokens {
-		switch token.Type {
-		case TokenSlash:
-			b.WriteByte('/')
-		case TokenEOF:
-		case TokenColon:
-			b.WriteByte(':')
-		case TokenLiteral:
-			if token.Value == "**" {
-				b.WriteString("{http_path}")
-			} else {
-				b.WriteString(token.Value)
-			}
-		case TokenIdent:
-			b.WriteString(token.Value)
-		case TokenVariable:
-			// Handle the name= prefix by extracting just the path portion
-			if strings.Contains(token.Value, "=") {
-				matches := namedPathPattern.FindStringSubmatch("{" + token.Value + "}")
-				if len(matches) == 3 {
-					if matches[2] == "**" {
-						b.WriteString("{")
-						b.WriteString(matches[1])
-						b.WriteString("}")
-						continue
-					}
-					// Convert the path from the starred form to use named path parameters.
-					// The starred path may be in the form "things/*/otherthings/*" or contain
-					// literal segments like "things/*/static/otherthings/*".
-					starredPath := matches[2]
-					parts := strings.Split(starredPath, "/")
-					for i, part := range parts {
-						if part != "*" || i == 0 {
-							continue
-						}
-						section := parts[i-1]
-						namedPathParameter := util.Singular(section)
-						parts[i] = "{" + namedPathParameter + "}"
-					}
-					// Rewrite the path to use the path parameters.
-					newPath := strings.Join(parts, "/")
-					b.WriteString(newPath)
+			segmentParams := namedPathParameters(opts, md, variable)
+			for i, s := range variable.Segments {
+				if segmentParam, ok := segmentParams[i]; ok {
+					parts = append(parts, "{"+segmentParam.Name+"}")
 				} else {
-					b.WriteByte('{')
-					b.WriteString(token.Value)
-					b.WriteByte('}')
+					parts = append(parts, s.String())
 				}
-			} else {
-				b.WriteByte('{')
-				b.WriteString(token.Value)
-				b.WriteByte('}')
 			}
+		default:
+			parts = append(parts, segment.String())
 		}
 	}
-	return b.String()
+	path := "/" + strings.Join(parts, "/")
+	if tmpl.Verb != "" {
+		path += ":" + tmpl.Verb
+	}
+	return path
+}
+
+// wildcardParameterName returns the name of the path parameter of the nth `*` segment that isn't part of a
+// variable.
+func wildcardParameterName(n int) string {
+	return fmt.Sprintf("path_%d", n)
+}
+
+func isSingleDeepWildcard(variable *TemplateVariable) bool {
+	return len(variable.Segments) == 1 && variable.Segments[0].Kind == SegmentDeepWildcard
 }
 
 func flattenToParams(opts options.Options, md protoreflect.MessageDescriptor, prefix string, seen map[string]struct{}) []*v3.Parameter {
@@ -517,6 +566,9 @@ func flattenToParams(opts options.Options, md protoreflect.MessageDescriptor, pr
 			continue
 		}
 		seen[string(field.FullName())] = struct{}{}
+		if schema.IsStrippedField(opts, field) || visibility.IsHidden(opts, field) {
+			continue
+		}
 		switch field.Kind() {
 		case protoreflect.MessageKind:
 			if util.IsWellKnown(field.Message()) {
@@ -5,27 +5,34 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-)
-
-func TestPartsToOpenAPIPath(t *testing.T) {
-	t.Run("with annotation", func(t *testing.T) {
-		v, err := RunPathPatternLexer("/pet/{pet_id}:addPet")
-		require.NoError(t, err)
-		path := partsToOpenAPIPath(v)
-		assert.Equal(t, "/pet/{pet_id}:addPet", path)
-	})
 
-	t.Run("with glob pattern", func(t *testing.T) {
-		v, err := RunPathPatternLexer("/users/v1/{name=organizations/*/teams/*/members/*}:activate")
-		require.NoError(t, err)
-		path := partsToOpenAPIPath(v)
-		assert.Equal(t, "/users/v1/organizations/{organization}/teams/{team}/members/{member}:activate", path)
-	})
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/options"
+)
 
-	t.Run("with glob pattern containing literal segment", func(t *testing.T) {
-		v, err := RunPathPatternLexer("/users/v1/{name=organizations/*/teams/*/all/members/*}:activate")
-		require.NoError(t, err)
-		path := partsToOpenAPIPath(v)
-		assert.Equal(t, "/users/v1/organizations/{organization}/teams/{team}/all/members/{member}:activate", path)
-	})
+func TestTemplateToOpenAPIPath(t *testing.T) {
+	testCases := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{name: "with annotation", template: "/pet/{pet_id}:addPet", expected: "/pet/{pet_id}:addPet"},
+		{name: "with glob pattern", template: "/users/v1/{name=organizations/*/teams/*/members/*}:activate", expected: "/users/v1/organizations/{organization}/teams/{team}/members/{member}:activate"},
+		{name: "with glob pattern containing literal segment", template: "/users/v1/{name=organizations/*/teams/*/all/members/*}:activate", expected: "/users/v1/organizations/{organization}/teams/{team}/all/members/{member}:activate"},
+		{name: "with custom method on named pattern", template: "/v1/{name=operations/*}:cancel", expected: "/v1/operations/{operation}:cancel"},
+		{name: "with explicit single wildcard", template: "/v1/{name=*}", expected: "/v1/{name}"},
+		{name: "with leading wildcard", template: "/v1/{name=*/books/*}", expected: "/v1/{name}/books/{book}"},
+		{name: "with deep wildcard variable", template: "/v1/{name=**}", expected: "/v1/{name}"},
+		{name: "with deep wildcard in named pattern", template: "/v1/{name=buckets/*/objects/**}:get", expected: "/v1/buckets/{bucket}/objects/{object}:get"},
+		{name: "with unnamed deep wildcard", template: "/v1/files/**", expected: "/v1/files/{http_path}"},
+		{name: "with unnamed wildcard", template: "/v1/*/files", expected: "/v1/{path_0}/files"},
+		{name: "with unnamed wildcards", template: "/v1/*/files/*/{name}", expected: "/v1/{path_0}/files/{path_1}/{name}"},
+		{name: "with field path", template: "/v1/messages/{message_id}/{sub.subfield}", expected: "/v1/messages/{message_id}/{sub.subfield}"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl, err := ParsePathTemplate(tc.template)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, templateToOpenAPIPath(options.Options{}, nil, tmpl))
+		})
+	}
 }
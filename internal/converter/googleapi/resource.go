@@ -0,0 +1,309 @@
+package googleapi
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+	"github.com/pb33f/libopenapi/orderedmap"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/options"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/util"
+	"go.yaml.in/yaml/v4"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// resourcePatternVariable matches variables in resource name patterns like "projects/{project}/books/{book}"
+var resourcePatternVariable = regexp.MustCompile(`^{([^}]+)}$`)
+
+// Resource is a google.api.resource descriptor along with the message that declares it, if any.
+type Resource struct {
+	Descriptor *annotations.ResourceDescriptor
+	Message    protoreflect.MessageDescriptor
+}
+
+// Link returns a markdown link to the schema of the resource, or just the resource type when the resource is
+// declared at the file level.
+func (r *Resource) Link() string {
+	if r.Message == nil {
+		return "`" + r.Descriptor.GetType() + "`"
+	}
+	return fmt.Sprintf("[%s](#/components/schemas/%s)", r.Descriptor.GetType(), util.FormatTypeRef(string(r.Message.FullName())))
+}
+
+// singular returns the singular name of the resource, falling back to the last part of the type name.
+func (r *Resource) singular() string {
+	if singular := r.Descriptor.GetSingular(); singular != "" {
+		return singular
+	}
+	_, kind, _ := strings.Cut(r.Descriptor.GetType(), "/")
+	return strings.ToLower(kind)
+}
+
+// pathSegmentParameter describes the path parameter for a single `*` segment of a named path pattern.
+type pathSegmentParameter struct {
+	Name        string
+	Description string
+	Pattern     string
+	// AllowReserved is set for `**` segments, which can contain '/'
+	AllowReserved bool
+}
+
+// MessageResource returns the resource declared on the message with the google.api.resource option.
+func MessageResource(md protoreflect.MessageDescriptor) *Resource {
+	res, ok := proto.GetExtension(md.Options(), annotations.E_Resource).(*annotations.ResourceDescriptor)
+	if !ok || res == nil {
+		return nil
+	}
+	return &Resource{Descriptor: res, Message: md}
+}
+
+// FindResource looks up a resource by its type in the given file and everything it imports. Resources can
+// be declared on messages or at the file level with google.api.resource_definition.
+func FindResource(fd protoreflect.FileDescriptor, resourceType string) *Resource {
+	return findResource(fd, resourceType, map[string]struct{}{})
+}
+
+func findResource(fd protoreflect.FileDescriptor, resourceType string, seen map[string]struct{}) *Resource {
+	if _, ok := seen[fd.Path()]; ok {
+		return nil
+	}
+	seen[fd.Path()] = struct{}{}
+	if defs, ok := proto.GetExtension(fd.Options(), annotations.E_ResourceDefinition).([]*annotations.ResourceDescriptor); ok {
+		for _, def := range defs {
+			if def.GetType() == resourceType {
+				return &Resource{Descriptor: def}
+			}
+		}
+	}
+	if res := findMessageResource(fd.Messages(), resourceType); res != nil {
+		return res
+	}
+	imports := fd.Imports()
+	for i := 0; i < imports.Len(); i++ {
+		if res := findResource(imports.Get(i).FileDescriptor, resourceType, seen); res != nil {
+			return res
+		}
+	}
+	return nil
+}
+
+func findMessageResource(messages protoreflect.MessageDescriptors, resourceType string) *Resource {
+	for i := 0; i < messages.Len(); i++ {
+		md := messages.Get(i)
+		if res := MessageResource(md); res != nil && res.Descriptor.GetType() == resourceType {
+			return res
+		}
+		if res := findMessageResource(md.Messages(), resourceType); res != nil {
+			return res
+		}
+	}
+	return nil
+}
+
+// fieldResource returns the resource that the value of the given field names. It returns whether the field
+// holds the name of the parent of the resource, which is the case for `child_type` references.
+func fieldResource(field protoreflect.FieldDescriptor) (*Resource, bool) {
+	if ref, ok := proto.GetExtension(field.Options(), annotations.E_ResourceReference).(*annotations.ResourceReference); ok && ref != nil {
+		if ref.GetType() != "" && ref.GetType() != "*" {
+			return FindResource(field.ParentFile(), ref.GetType()), false
+		}
+		if ref.GetChildType() != "" {
+			return FindResource(field.ParentFile(), ref.GetChildType()), true
+		}
+	}
+	res := MessageResource(field.ContainingMessage())
+	if res == nil {
+		return nil, false
+	}
+	nameField := res.Descriptor.GetNameField()
+	if nameField == "" {
+		nameField = "name"
+	}
+	if string(field.Name()) != nameField {
+		return nil, false
+	}
+	return res, false
+}
+
+// resourcePathParameters matches a starred path like "projects/*/books/*" against the patterns of the resource
+// that the field refers to. The result is keyed by the index of each `*` segment in the starred path.
+func resourcePathParameters(field protoreflect.FieldDescriptor, starredPath string) map[int]pathSegmentParameter {
+	if field == nil {
+		return nil
+	}
+	res, isParent := fieldResource(field)
+	if res == nil {
+		return nil
+	}
+	parts := strings.Split(starredPath, "/")
+	for _, pattern := range res.Descriptor.GetPattern() {
+		patternParts := strings.Split(pattern, "/")
+		if isParent {
+			// The parent of the resource is the pattern without the trailing collection and resource ID
+			if len(patternParts) < 2 {
+				continue
+			}
+			patternParts = patternParts[:len(patternParts)-2]
+		}
+		if params := matchResourcePattern(res, parts, patternParts, !isParent); params != nil {
+			return params
+		}
+	}
+	return nil
+}
+
+func matchResourcePattern(res *Resource, parts, patternParts []string, isResource bool) map[int]pathSegmentParameter {
+	if len(parts) != len(patternParts) {
+		return nil
+	}
+	params := map[int]pathSegmentParameter{}
+	for i, part := range parts {
+		matches := resourcePatternVariable.FindStringSubmatch(patternParts[i])
+		if part != "*" {
+			if matches != nil || part != patternParts[i] {
+				return nil
+			}
+			continue
+		}
+		if matches == nil {
+			return nil
+		}
+		param := pathSegmentParameter{
+			Name:        matches[1],
+			Description: "The " + matches[1] + " id.",
+			Pattern:     "^[^/]+$",
+		}
+		if isResource && i == len(parts)-1 {
+			param.Description = fmt.Sprintf("The %s id. Part of the resource name of a %s resource.", res.singular(), res.Link())
+			if plural := res.Descriptor.GetPlural(); plural != "" {
+				param.Description = fmt.Sprintf("The %s id within the `%s` collection. Part of the resource name of a %s resource.", res.singular(), plural, res.Link())
+			}
+		}
+		params[i] = param
+	}
+	return params
+}
+
+// namedPathParameters returns the path parameters for the wildcard segments of a variable such as
+// {name=projects/*/books/*}, keyed by the index of the segment. Names come from the resource patterns when the
+// field refers to a resource and are otherwise derived from the preceding literal segment.
+func namedPathParameters(opts options.Options, md protoreflect.MessageDescriptor, variable *TemplateVariable) map[int]pathSegmentParameter {
+	var params map[int]pathSegmentParameter
+	if md != nil && !isHiddenFieldPath(opts, md, variable.FieldPath) {
+		field, _ := resolveField(opts, md, variable.FieldPath)
+		params = resourcePathParameters(field, segmentsString(variable.Segments))
+	}
+	if params == nil {
+		params = map[int]pathSegmentParameter{}
+	}
+	used := map[string]struct{}{}
+	for _, param := range params {
+		used[param.Name] = struct{}{}
+	}
+	for i, segment := range variable.Segments {
+		if _, ok := params[i]; ok {
+			continue
+		}
+		if segment.Kind != SegmentWildcard && segment.Kind != SegmentDeepWildcard {
+			continue
+		}
+		name := variable.FieldPath[strings.LastIndex(variable.FieldPath, ".")+1:]
+		if i > 0 && variable.Segments[i-1].Kind == SegmentLiteral {
+			name = util.Singular(variable.Segments[i-1].Literal)
+		}
+		if _, ok := used[name]; ok {
+			name = fmt.Sprintf("%s_%d", name, i)
+		}
+		used[name] = struct{}{}
+		if segment.Kind == SegmentDeepWildcard {
+			params[i] = pathSegmentParameter{Name: name, Description: "The trailing part of the path.", AllowReserved: true}
+			continue
+		}
+		params[i] = pathSegmentParameter{Name: name, Description: "The " + name + " id.", Pattern: "^[^/]+$"}
+	}
+	return params
+}
+
+// resourceNameRegex converts the patterns of a resource into a regular expression that matches full resource
+// names.
+func resourceNameRegex(patterns []string) string {
+	alternatives := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		parts := strings.Split(pattern, "/")
+		for i, part := range parts {
+			if resourcePatternVariable.MatchString(part) {
+				parts[i] = "[^/]+"
+			} else {
+				parts[i] = regexp.QuoteMeta(part)
+			}
+		}
+		alternatives = append(alternatives, strings.Join(parts, "/"))
+	}
+	if len(alternatives) == 0 {
+		return ""
+	}
+	if len(alternatives) == 1 {
+		return "^" + alternatives[0] + "$"
+	}
+	return "^(" + strings.Join(alternatives, "|") + ")$"
+}
+
+type resourceExtension struct {
+	Type      string   `yaml:"type"`
+	Pattern   []string `yaml:"pattern,omitempty"`
+	NameField string   `yaml:"nameField,omitempty"`
+	Plural    string   `yaml:"plural,omitempty"`
+	Singular  string   `yaml:"singular,omitempty"`
+}
+
+// SchemaWithMessageAnnotations adds `x-google-resource` metadata to schemas of messages that are annotated
+// with google.api.resource.
+func SchemaWithMessageAnnotations(opts options.Options, schema *base.Schema, desc protoreflect.MessageDescriptor) *base.Schema {
+	res := MessageResource(desc)
+	if res == nil {
+		return schema
+	}
+	node := &yaml.Node{}
+	if err := node.Encode(resourceExtension{
+		Type:      res.Descriptor.GetType(),
+		Pattern:   res.Descriptor.GetPattern(),
+		NameField: res.Descriptor.GetNameField(),
+		Plural:    res.Descriptor.GetPlural(),
+		Singular:  res.Descriptor.GetSingular(),
+	}); err != nil {
+		opts.Logger.Warn("unable to encode google.api.resource", "error", err)
+		return schema
+	}
+	if schema.Extensions == nil {
+		schema.Extensions = orderedmap.New[string, *yaml.Node]()
+	}
+	schema.Extensions.Set("x-google-resource", node)
+	return schema
+}
+
+// schemaWithResourceReference describes which resource the value of a resource name field refers to.
+func schemaWithResourceReference(schema *base.Schema, desc protoreflect.FieldDescriptor) *base.Schema {
+	if !proto.HasExtension(desc.Options(), annotations.E_ResourceReference) {
+		return schema
+	}
+	res, isParent := fieldResource(desc)
+	if res == nil {
+		return schema
+	}
+	note := "The resource name of a " + res.Link() + " resource."
+	if isParent {
+		note = "The resource name of the parent of a " + res.Link() + " resource."
+	} else if pattern := resourceNameRegex(res.Descriptor.GetPattern()); pattern != "" && schema.Pattern == "" && !slices.Contains(schema.Type, "array") {
+		schema.Pattern = pattern
+	}
+	if schema.Description == "" {
+		schema.Description = note
+	} else {
+		schema.Description += "\n\n" + note
+	}
+	return schema
+}
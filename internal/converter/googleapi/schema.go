@@ -51,6 +51,7 @@ func IsFieldRequired(desc protoreflect.FieldDescriptor) *bool {
 }
 
 func SchemaWithPropertyAnnotations(opts options.Options, schema *base.Schema, desc protoreflect.FieldDescriptor) *base.Schema {
+	schema = schemaWithResourceReference(schema, desc)
 	dopts := desc.Options()
 	if !proto.HasExtension(dopts, annotations.E_FieldBehavior) {
 		return schema
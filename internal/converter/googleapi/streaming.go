@@ -0,0 +1,72 @@
+package googleapi
+
+import (
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+	"github.com/pb33f/libopenapi/orderedmap"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/options"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/util"
+)
+
+// streamSchemaName returns the name of the schema for a single message of the response stream of a
+// server-streaming method.
+func streamSchemaName(md protoreflect.MethodDescriptor) string {
+	return util.FormatTypeRef(string(md.Output().FullName())) + ".stream"
+}
+
+// streamChunkSchema wraps the schema of a streamed message like grpc-gateway and Vanguard do. Each message
+// of the stream holds either a result or the error that ended the stream.
+func streamChunkSchema(result *base.SchemaProxy) *base.Schema {
+	props := orderedmap.New[string, *base.SchemaProxy]()
+	props.Set("result", result)
+	props.Set("error", base.CreateSchemaProxyRef("#/components/schemas/google.rpc.Status"))
+	return &base.Schema{
+		Type:        []string{"object"},
+		Description: "A message of the response stream. It holds either the result or the error that ended the stream.",
+		Properties:  props,
+	}
+}
+
+// streamMediaTypes creates the media types of a streamed response. OpenAPI 3.2 describes each message of the
+// stream with `itemSchema`. OpenAPI 3.1 has no way to describe the items, so `schema` is used instead.
+func streamMediaTypes(opts options.Options, chunk *base.SchemaProxy) *orderedmap.Map[string, *v3.MediaType] {
+	mediaTypes := orderedmap.New[string, *v3.MediaType]()
+	for _, format := range opts.RESTStreamFormats {
+		var contentType string
+		var item *base.SchemaProxy
+		switch format {
+		case options.RESTStreamFormatNDJSON:
+			contentType, item = "application/x-ndjson", chunk
+		case options.RESTStreamFormatSSE:
+			contentType, item = "text/event-stream", serverSentEventSchema(chunk)
+		default:
+			continue
+		}
+		if opts.IsOpenAPI32() {
+			mediaTypes.Set(contentType, &v3.MediaType{ItemSchema: item})
+		} else {
+			mediaTypes.Set(contentType, &v3.MediaType{Schema: item})
+		}
+	}
+	return mediaTypes
+}
+
+// serverSentEventSchema describes a server-sent event whose data is a JSON encoded message of the stream.
+func serverSentEventSchema(chunk *base.SchemaProxy) *base.SchemaProxy {
+	props := orderedmap.New[string, *base.SchemaProxy]()
+	props.Set("event", base.CreateSchemaProxy(&base.Schema{Type: []string{"string"}}))
+	props.Set("data", base.CreateSchemaProxy(&base.Schema{
+		Type:             []string{"string"},
+		ContentMediaType: "application/json",
+		ContentSchema:    chunk,
+	}))
+	props.Set("id", base.CreateSchemaProxy(&base.Schema{Type: []string{"string"}}))
+	props.Set("retry", base.CreateSchemaProxy(&base.Schema{Type: []string{"integer"}}))
+	return base.CreateSchemaProxy(&base.Schema{
+		Type:       []string{"object"},
+		Required:   []string{"data"},
+		Properties: props,
+	})
+}
@@ -0,0 +1,318 @@
+package googleapi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PathTemplate is a parsed google.api.http path template. The grammar is defined in google/api/http.proto:
+//
+//	Template = "/" Segments [ Verb ] ;
+//	Segments = Segment { "/" Segment } ;
+//	Segment  = "*" | "**" | LITERAL | Variable ;
+//	Variable = "{" FieldPath [ "=" Segments ] "}" ;
+//	FieldPath = IDENT { "." IDENT } ;
+//	Verb     = ":" LITERAL ;
+type PathTemplate struct {
+	Segments []TemplateSegment
+	Verb     string
+}
+
+// SegmentKind is the kind of a single path template segment.
+type SegmentKind int
+
+const (
+	// SegmentLiteral matches the literal text of the segment.
+	SegmentLiteral SegmentKind = iota
+	// SegmentWildcard (`*`) matches a single path segment.
+	SegmentWildcard
+	// SegmentDeepWildcard (`**`) matches zero or more path segments.
+	SegmentDeepWildcard
+	// SegmentVariable captures the segments it matches into a request field.
+	SegmentVariable
+)
+
+// TemplateSegment is a single segment of a path template.
+type TemplateSegment struct {
+	Kind     SegmentKind
+	Literal  string
+	Variable *TemplateVariable
+}
+
+// TemplateVariable is a variable of a path template. A variable without an explicit template, like
+// `{name}`, is equivalent to `{name=*}`.
+type TemplateVariable struct {
+	FieldPath string
+	Segments  []TemplateSegment
+}
+
+// TemplateError is returned when a path template doesn't follow the google.api.http grammar.
+type TemplateError struct {
+	Template string
+	Pos      int
+	Msg      string
+}
+
+func (e *TemplateError) Error() string {
+	return fmt.Sprintf("invalid path template %q at position %d: %s", e.Template, e.Pos, e.Msg)
+}
+
+// ParsePathTemplate parses a google.api.http path template.
+func ParsePathTemplate(template string) (*PathTemplate, error) {
+	p := &templateParser{input: template, variables: map[string]struct{}{}, deepWildcardPos: -1}
+	if !p.consume('/') {
+		return nil, p.errorf("template must start with '/'")
+	}
+	tmpl := &PathTemplate{}
+	// A template of just "/" has no segments
+	if !p.eof() {
+		segments, err := p.parseSegments(false)
+		if err != nil {
+			return nil, err
+		}
+		tmpl.Segments = segments
+	}
+	if p.consume(':') {
+		verb, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		if !p.eof() {
+			return nil, p.errorf("the verb must be the last part of the template")
+		}
+		tmpl.Verb = verb
+	}
+	if !p.eof() {
+		return nil, p.errorf("unexpected %q", p.peek())
+	}
+	return tmpl, nil
+}
+
+// Variables returns the variables of the template in order.
+func (t *PathTemplate) Variables() []*TemplateVariable {
+	variables := []*TemplateVariable{}
+	for _, segment := range t.Segments {
+		if segment.Kind == SegmentVariable {
+			variables = append(variables, segment.Variable)
+		}
+	}
+	return variables
+}
+
+// String returns the canonical form of the template.
+func (t *PathTemplate) String() string {
+	s := "/" + segmentsString(t.Segments)
+	if t.Verb != "" {
+		s += ":" + t.Verb
+	}
+	return s
+}
+
+// IsSingleWildcard reports whether the variable matches exactly one path segment of any value, like `{name}`.
+func (v *TemplateVariable) IsSingleWildcard() bool {
+	return len(v.Segments) == 1 && v.Segments[0].Kind == SegmentWildcard
+}
+
+// IsMultiSegment reports whether the variable can match more than one path segment. Reserved characters,
+// including '/', are not percent-encoded in the values of these variables.
+func (v *TemplateVariable) IsMultiSegment() bool {
+	return len(v.Segments) > 1 || v.Segments[0].Kind == SegmentDeepWildcard
+}
+
+// String returns the canonical form of the variable.
+func (v *TemplateVariable) String() string {
+	if v.IsSingleWildcard() {
+		return "{" + v.FieldPath + "}"
+	}
+	return "{" + v.FieldPath + "=" + segmentsString(v.Segments) + "}"
+}
+
+func (s TemplateSegment) String() string {
+	switch s.Kind {
+	case SegmentWildcard:
+		return "*"
+	case SegmentDeepWildcard:
+		return "**"
+	case SegmentVariable:
+		return s.Variable.String()
+	default:
+		return s.Literal
+	}
+}
+
+func segmentsString(segments []TemplateSegment) string {
+	parts := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		parts = append(parts, segment.String())
+	}
+	return strings.Join(parts, "/")
+}
+
+type templateParser struct {
+	input string
+	pos   int
+	// variables are the field paths of the variables that were parsed so far.
+	variables map[string]struct{}
+	// deepWildcardPos is the position of the `**` segment that was parsed, or -1. It must be the last segment.
+	deepWildcardPos int
+}
+
+func (p *templateParser) eof() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *templateParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *templateParser) consume(c byte) bool {
+	if p.eof() || p.peek() != c {
+		return false
+	}
+	p.pos++
+	return true
+}
+
+func (p *templateParser) errorf(format string, args ...any) error {
+	return p.errorAt(p.pos, format, args...)
+}
+
+func (p *templateParser) errorAt(pos int, format string, args ...any) error {
+	return &TemplateError{Template: p.input, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *templateParser) parseSegments(inVariable bool) ([]TemplateSegment, error) {
+	segments := []TemplateSegment{}
+	for {
+		segment, err := p.parseSegment(inVariable)
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, segment)
+		if !p.consume('/') {
+			return segments, nil
+		}
+	}
+}
+
+func (p *templateParser) parseSegment(inVariable bool) (TemplateSegment, error) {
+	if p.deepWildcardPos >= 0 {
+		return TemplateSegment{}, p.errorAt(p.deepWildcardPos, "'**' must be the last segment of the path")
+	}
+	switch p.peek() {
+	case '*':
+		start := p.pos
+		p.pos++
+		if p.consume('*') {
+			p.deepWildcardPos = start
+			return TemplateSegment{Kind: SegmentDeepWildcard}, nil
+		}
+		return TemplateSegment{Kind: SegmentWildcard}, nil
+	case '{':
+		if inVariable {
+			return TemplateSegment{}, p.errorf("variables can't be nested")
+		}
+		variable, err := p.parseVariable()
+		if err != nil {
+			return TemplateSegment{}, err
+		}
+		return TemplateSegment{Kind: SegmentVariable, Variable: variable}, nil
+	default:
+		literal, err := p.parseLiteral()
+		if err != nil {
+			return TemplateSegment{}, err
+		}
+		return TemplateSegment{Kind: SegmentLiteral, Literal: literal}, nil
+	}
+}
+
+func (p *templateParser) parseVariable() (*TemplateVariable, error) {
+	start := p.pos
+	p.pos++ // {
+	fieldPath, err := p.parseFieldPath()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.variables[fieldPath]; ok {
+		return nil, p.errorAt(start, "variable %q is used more than once", fieldPath)
+	}
+	p.variables[fieldPath] = struct{}{}
+	variable := &TemplateVariable{
+		FieldPath: fieldPath,
+		Segments:  []TemplateSegment{{Kind: SegmentWildcard}},
+	}
+	if p.consume('=') {
+		segments, err := p.parseSegments(true)
+		if err != nil {
+			return nil, err
+		}
+		variable.Segments = segments
+	}
+	if !p.consume('}') {
+		if p.eof() {
+			return nil, p.errorf("unterminated variable %q", fieldPath)
+		}
+		return nil, p.errorf("unexpected %q in variable %q", p.peek(), fieldPath)
+	}
+	return variable, nil
+}
+
+func (p *templateParser) parseFieldPath() (string, error) {
+	start := p.pos
+	for {
+		identStart := p.pos
+		for !p.eof() && isIdentChar(p.peek(), p.pos == identStart) {
+			p.pos++
+		}
+		if p.pos == identStart {
+			return "", p.errorf("expected a field name")
+		}
+		if !p.consume('.') {
+			return p.input[start:p.pos], nil
+		}
+	}
+}
+
+// parseLiteral reads a literal segment. Literals can contain any character that is valid in a URL path
+// segment except for the characters that have a meaning in the template grammar.
+func (p *templateParser) parseLiteral() (string, error) {
+	start := p.pos
+	for !p.eof() && !strings.ContainsRune("/{}*:=", rune(p.peek())) {
+		c := p.peek()
+		if c <= ' ' || c >= 0x7f || strings.ContainsRune(`"<>\^`+"`|#?[]", rune(c)) {
+			return "", p.errorf("invalid character %q in literal", c)
+		}
+		if c == '%' && !isPercentEncoded(p.input[p.pos:]) {
+			return "", p.errorf("invalid percent-encoding in literal")
+		}
+		p.pos++
+	}
+	if p.pos == start {
+		if p.eof() {
+			return "", p.errorf("unexpected end of template")
+		}
+		return "", p.errorf("unexpected %q", p.peek())
+	}
+	return p.input[start:p.pos], nil
+}
+
+func isIdentChar(c byte, first bool) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_':
+		return true
+	case c >= '0' && c <= '9':
+		return !first
+	}
+	return false
+}
+
+func isPercentEncoded(s string) bool {
+	return len(s) >= 3 && isHex(s[1]) && isHex(s[2])
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
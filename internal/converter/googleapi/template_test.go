@@ -0,0 +1,179 @@
+package googleapi_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/googleapi"
+)
+
+// httpProtoExamples are the path templates used in the documentation of google/api/http.proto
+var httpProtoExamples = []string{
+	"/v1/{name=messages/*}",
+	"/v1/messages/{message_id}",
+	"/v1/messages/{message_id}/{sub.subfield}",
+	"/v1/users/{user_id}/messages/{message_id}",
+	"/v1/users/{user_id}/messages",
+	"/v1/messages",
+	"/v1/{name=projects/*/topics/*}",
+	"/v1/{name=projects/*/topics/*}:publish",
+	"/v1/{resource=**}:getIamPolicy",
+	"/v1/{name=buckets/*/objects/**}",
+	"/v1/projects/{project}/locations/{location}/operations/{operation}:cancel",
+	"/v1/{name=shelves/*/books/*}",
+	"/v1/{book.name=shelves/*/books/*}",
+	"/v1/files/**",
+	"/v1/*/files",
+	"/",
+}
+
+func TestParsePathTemplate(t *testing.T) {
+	wildcard := googleapi.TemplateSegment{Kind: googleapi.SegmentWildcard}
+	literal := func(s string) googleapi.TemplateSegment {
+		return googleapi.TemplateSegment{Kind: googleapi.SegmentLiteral, Literal: s}
+	}
+	variable := func(fieldPath string, segments ...googleapi.TemplateSegment) googleapi.TemplateSegment {
+		if len(segments) == 0 {
+			segments = []googleapi.TemplateSegment{wildcard}
+		}
+		return googleapi.TemplateSegment{Kind: googleapi.SegmentVariable, Variable: &googleapi.TemplateVariable{FieldPath: fieldPath, Segments: segments}}
+	}
+
+	testCases := []struct {
+		template string
+		expected *googleapi.PathTemplate
+	}{
+		{
+			template: "/",
+			expected: &googleapi.PathTemplate{},
+		},
+		{
+			template: "/v1/messages/{message_id}",
+			expected: &googleapi.PathTemplate{Segments: []googleapi.TemplateSegment{literal("v1"), literal("messages"), variable("message_id")}},
+		},
+		{
+			template: "/v1/messages/{message_id}/{sub.subfield}",
+			expected: &googleapi.PathTemplate{Segments: []googleapi.TemplateSegment{literal("v1"), literal("messages"), variable("message_id"), variable("sub.subfield")}},
+		},
+		{
+			template: "/v1/{name=messages/*}",
+			expected: &googleapi.PathTemplate{Segments: []googleapi.TemplateSegment{literal("v1"), variable("name", literal("messages"), wildcard)}},
+		},
+		{
+			template: "/v1/{name=operations/*}:cancel",
+			expected: &googleapi.PathTemplate{Segments: []googleapi.TemplateSegment{literal("v1"), variable("name", literal("operations"), wildcard)}, Verb: "cancel"},
+		},
+		{
+			template: "/v1/{resource=**}:getIamPolicy",
+			expected: &googleapi.PathTemplate{Segments: []googleapi.TemplateSegment{literal("v1"), variable("resource", googleapi.TemplateSegment{Kind: googleapi.SegmentDeepWildcard})}, Verb: "getIamPolicy"},
+		},
+		{
+			template: "/v1/users:import",
+			expected: &googleapi.PathTemplate{Segments: []googleapi.TemplateSegment{literal("v1"), literal("users")}, Verb: "import"},
+		},
+		{
+			template: "/v1/*/files/**",
+			expected: &googleapi.PathTemplate{Segments: []googleapi.TemplateSegment{literal("v1"), wildcard, literal("files"), {Kind: googleapi.SegmentDeepWildcard}}},
+		},
+		{
+			template: "/v1/caf%C3%A9s/{id}",
+			expected: &googleapi.PathTemplate{Segments: []googleapi.TemplateSegment{literal("v1"), literal("caf%C3%A9s"), variable("id")}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.template, func(t *testing.T) {
+			tmpl, err := googleapi.ParsePathTemplate(tc.template)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, tmpl)
+		})
+	}
+
+	t.Run("http.proto examples", func(t *testing.T) {
+		for _, template := range httpProtoExamples {
+			tmpl, err := googleapi.ParsePathTemplate(template)
+			require.NoError(t, err, template)
+			assert.Equal(t, template, tmpl.String())
+		}
+	})
+
+	t.Run("variables", func(t *testing.T) {
+		tmpl, err := googleapi.ParsePathTemplate("/v1/{parent=projects/*}/books/{book_id}/{path=files/**}")
+		require.NoError(t, err)
+		variables := tmpl.Variables()
+		require.Len(t, variables, 3)
+		assert.Equal(t, "parent", variables[0].FieldPath)
+		assert.False(t, variables[0].IsSingleWildcard())
+		assert.True(t, variables[0].IsMultiSegment())
+		assert.True(t, variables[1].IsSingleWildcard())
+		assert.False(t, variables[1].IsMultiSegment())
+		assert.True(t, variables[2].IsMultiSegment())
+	})
+}
+
+func TestParsePathTemplateErrors(t *testing.T) {
+	testCases := []struct {
+		template string
+		pos      int
+		msg      string
+	}{
+		{template: "", pos: 0, msg: "template must start with '/'"},
+		{template: "v1/messages", pos: 0, msg: "template must start with '/'"},
+		{template: "/v1//messages", pos: 4, msg: `unexpected '/'`},
+		{template: "/v1/messages/", pos: 13, msg: "unexpected end of template"},
+		{template: "/v1/{name", pos: 9, msg: `unterminated variable "name"`},
+		{template: "/v1/{}", pos: 5, msg: "expected a field name"},
+		{template: "/v1/{1name}", pos: 5, msg: "expected a field name"},
+		{template: "/v1/{name.}", pos: 10, msg: "expected a field name"},
+		{template: "/v1/{name=messages/{id}}", pos: 19, msg: "variables can't be nested"},
+		{template: "/v1/{name=messages/*", pos: 20, msg: `unterminated variable "name"`},
+		{template: "/v1/**/messages", pos: 4, msg: "'**' must be the last segment of the path"},
+		{template: "/v1/{name=**}/messages", pos: 10, msg: "'**' must be the last segment of the path"},
+		{template: "/v1/{id}/{id}", pos: 9, msg: `variable "id" is used more than once`},
+		{template: "/v1/{name=shelves/*}/{id}/{name}", pos: 26, msg: `variable "name" is used more than once`},
+		{template: "/v1/{name=files/**}/**", pos: 16, msg: "'**' must be the last segment of the path"},
+		{template: "/v1/messages:", pos: 13, msg: "unexpected end of template"},
+		{template: "/v1/messages:cancel/now", pos: 19, msg: "the verb must be the last part of the template"},
+		{template: "/v1/mess ages", pos: 8, msg: `invalid character ' ' in literal`},
+		{template: "/v1/%zz", pos: 4, msg: "invalid percent-encoding in literal"},
+		{template: "/v1/messages}", pos: 12, msg: `unexpected '}'`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.template, func(t *testing.T) {
+			_, err := googleapi.ParsePathTemplate(tc.template)
+			var templateErr *googleapi.TemplateError
+			require.True(t, errors.As(err, &templateErr), "expected a TemplateError, got %v", err)
+			assert.Equal(t, tc.template, templateErr.Template)
+			assert.Equal(t, tc.pos, templateErr.Pos)
+			assert.Equal(t, tc.msg, templateErr.Msg)
+		})
+	}
+}
+
+func FuzzParsePathTemplate(f *testing.F) {
+	for _, template := range httpProtoExamples {
+		f.Add(template)
+	}
+	f.Fuzz(func(t *testing.T, template string) {
+		tmpl, err := googleapi.ParsePathTemplate(template)
+		if err != nil {
+			var templateErr *googleapi.TemplateError
+			if !errors.As(err, &templateErr) {
+				t.Fatalf("expected a TemplateError, got %T", err)
+			}
+			if templateErr.Pos < 0 || templateErr.Pos > len(template) {
+				t.Fatalf("error position %d is out of range for %q", templateErr.Pos, template)
+			}
+			return
+		}
+		// The canonical form of a template must parse to the same template
+		reparsed, err := googleapi.ParsePathTemplate(tmpl.String())
+		if err != nil {
+			t.Fatalf("canonical form %q of %q doesn't parse: %v", tmpl.String(), template, err)
+		}
+		if reparsed.String() != tmpl.String() {
+			t.Fatalf("canonical form changed from %q to %q", tmpl.String(), reparsed.String())
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package converter
+
+import (
+	"strings"
+
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+	"github.com/pb33f/libopenapi/orderedmap"
+	"github.com/pb33f/libopenapi/utils"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/options"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/util"
+	"go.yaml.in/yaml/v4"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// addCustomHeaders adds the custom headers that the config declares for the method to the operations of the
+// path item, as header parameters and as headers of every response.
+func addCustomHeaders(opts options.Options, method protoreflect.MethodDescriptor, item *v3.PathItem) {
+	request, response := opts.Config.MethodHeaders(method.FullName())
+	if len(request) == 0 && len(response) == 0 {
+		return
+	}
+	for op := range util.PathItemOperations(item).ValuesFromOldest() {
+		for _, header := range request {
+			if hasHeaderParameter(op, header.Name) {
+				continue
+			}
+			param := &v3.Parameter{
+				Name:        header.Name,
+				In:          "header",
+				Description: header.Description,
+				Deprecated:  header.Deprecated,
+				Schema:      base.CreateSchemaProxy(customHeaderSchema(header)),
+			}
+			if header.Required {
+				param.Required = util.BoolPtr(true)
+			}
+			op.Parameters = append(op.Parameters, param)
+		}
+		if op.Responses == nil || len(response) == 0 {
+			continue
+		}
+		responses := []*v3.Response{op.Responses.Default}
+		for resp := range op.Responses.Codes.ValuesFromOldest() {
+			responses = append(responses, resp)
+		}
+		for _, resp := range responses {
+			if resp == nil {
+				continue
+			}
+			if resp.Headers == nil {
+				resp.Headers = orderedmap.New[string, *v3.Header]()
+			}
+			for _, header := range response {
+				resp.Headers.Set(header.Name, &v3.Header{
+					Description: header.Description,
+					Required:    header.Required,
+					Deprecated:  header.Deprecated,
+					Schema:      base.CreateSchemaProxy(customHeaderSchema(header)),
+				})
+			}
+		}
+	}
+}
+
+func hasHeaderParameter(op *v3.Operation, name string) bool {
+	for _, param := range op.Parameters {
+		if param.In == "header" && strings.EqualFold(param.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func customHeaderSchema(header *options.Header) *base.Schema {
+	s := &base.Schema{
+		Type:    []string{"string"},
+		Format:  header.Format,
+		Pattern: header.Pattern,
+	}
+	if header.Type != "" {
+		s.Type = []string{header.Type}
+	}
+	for _, value := range header.Enum {
+		s.Enum = append(s.Enum, headerValueNode(s.Type[0], value))
+	}
+	if header.Example != "" {
+		s.Examples = []*yaml.Node{headerValueNode(s.Type[0], header.Example)}
+	}
+	return s
+}
+
+// headerValueNode returns a node for a value of a header with the given type.
+func headerValueNode(typ, value string) *yaml.Node {
+	node := utils.CreateStringNode(value)
+	switch typ {
+	case "integer":
+		node.Tag = "!!int"
+	case "number":
+		node.Tag = "!!float"
+	case "boolean":
+		node.Tag = "!!bool"
+	}
+	return node
+}
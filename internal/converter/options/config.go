@@ -0,0 +1,236 @@
+package options
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/gobwas/glob"
+	"go.yaml.in/yaml/v4"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Config is the contents of the YAML file given with the `config` option. It holds the settings that are too
+// structured to be given as plugin options.
+type Config struct {
+	// ErrorResponses declares the error codes and error details that methods can return. The first rule that
+	// matches a method is used.
+	ErrorResponses []*ErrorResponseRule `yaml:"errorResponses"`
+	// Headers declares the custom headers of methods, like headers that are forwarded as gRPC metadata. The
+	// headers of all rules that match a method are used.
+	Headers []*HeaderRule `yaml:"headers"`
+	// SecuritySchemes are OpenAPI security schemes, keyed by their name, that are added to the components.
+	SecuritySchemes yaml.Node `yaml:"securitySchemes"`
+	// Security declares the security requirements of methods. The first rule that matches a method is used.
+	Security []*SecurityRule `yaml:"security"`
+	// Extensions maps custom options to specification extensions.
+	Extensions []*ExtensionMapping `yaml:"extensions"`
+}
+
+// ExtensionMapping maps a custom option of files, services, methods, messages, fields, enums or enum values to
+// a specification extension of the OpenAPI node that is generated for them.
+type ExtensionMapping struct {
+	// Option is the fully qualified name of the option, like `acme.api.owner`.
+	Option string `yaml:"option"`
+	// Name is the name of the extension. It defaults to `x-` followed by the name of the option.
+	Name string `yaml:"name"`
+}
+
+// SecurityRule declares the security requirements of the matching methods.
+type SecurityRule struct {
+	// Methods are glob patterns of fully qualified method names. All methods match when this is empty.
+	Methods []string `yaml:"methods"`
+	// Option is the fully qualified name of a method option. Only the methods that set the option match. The
+	// values of a string option are used as the scopes of Scheme and a bool option that is true requires
+	// Scheme without scopes.
+	Option string `yaml:"option"`
+	// Scheme is the name of the security scheme that methods with Option require.
+	Scheme string `yaml:"scheme"`
+	// Requirements are the security requirements of the methods that don't use Option, as maps from
+	// scheme names to scopes. An empty list makes the methods public.
+	Requirements []map[string][]string `yaml:"requirements"`
+
+	patterns []glob.Glob
+}
+
+// Matches reports whether the method matches the method patterns of the rule.
+func (r *SecurityRule) Matches(method protoreflect.FullName) bool {
+	return len(r.patterns) == 0 || matchesMethod(r.patterns, method)
+}
+
+// HeaderRule declares the custom request and response headers of the matching methods.
+type HeaderRule struct {
+	// Methods are glob patterns of fully qualified method names, like `acme.billing.**`.
+	Methods []string `yaml:"methods"`
+	// Request are the headers that clients send.
+	Request []*Header `yaml:"request"`
+	// Response are the headers that servers send.
+	Response []*Header `yaml:"response"`
+
+	patterns []glob.Glob
+}
+
+// Header is a custom request or response header.
+type Header struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Required    bool   `yaml:"required"`
+	Deprecated  bool   `yaml:"deprecated"`
+	// Type is the JSON schema type of the value, defaults to `string`.
+	Type    string   `yaml:"type"`
+	Format  string   `yaml:"format"`
+	Pattern string   `yaml:"pattern"`
+	Enum    []string `yaml:"enum"`
+	Example string   `yaml:"example"`
+}
+
+// ErrorResponseRule declares the errors that the matching methods can return.
+type ErrorResponseRule struct {
+	// Methods are glob patterns of fully qualified method names, like `acme.pets.v1.PetService.*`.
+	Methods []string `yaml:"methods"`
+	// Codes are the Connect error codes, like `not_found`. All codes are possible when this is empty.
+	Codes []string `yaml:"codes"`
+	// Details are the fully qualified names of the error detail messages, like `google.rpc.BadRequest`.
+	Details []string `yaml:"details"`
+
+	patterns []glob.Glob
+}
+
+// ParseConfig parses the contents of a config file.
+func ParseConfig(b []byte) (*Config, error) {
+	config := &Config{}
+	// Unknown keys are rejected, so typos don't silently drop settings.
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	for i, rule := range config.ErrorResponses {
+		patterns, err := compileMethodPatterns(fmt.Sprintf("errorResponses[%d]", i), rule.Methods)
+		if err != nil {
+			return nil, err
+		}
+		rule.patterns = patterns
+	}
+	for i, rule := range config.Headers {
+		patterns, err := compileMethodPatterns(fmt.Sprintf("headers[%d]", i), rule.Methods)
+		if err != nil {
+			return nil, err
+		}
+		rule.patterns = patterns
+		for _, header := range slices.Concat(rule.Request, rule.Response) {
+			if header.Name == "" {
+				return nil, fmt.Errorf("invalid config: headers[%d] has a header without a name", i)
+			}
+		}
+	}
+	for i, rule := range config.Security {
+		if len(rule.Methods) > 0 {
+			patterns, err := compileMethodPatterns(fmt.Sprintf("security[%d]", i), rule.Methods)
+			if err != nil {
+				return nil, err
+			}
+			rule.patterns = patterns
+		}
+		switch {
+		case rule.Option != "" && rule.Scheme == "":
+			return nil, fmt.Errorf("invalid config: security[%d] has an option without a scheme", i)
+		case rule.Option == "" && rule.Requirements == nil:
+			return nil, fmt.Errorf("invalid config: security[%d] needs an option or requirements", i)
+		}
+	}
+	for i, mapping := range config.Extensions {
+		if mapping.Option == "" {
+			return nil, fmt.Errorf("invalid config: extensions[%d] has no option", i)
+		}
+		if mapping.Name == "" {
+			mapping.Name = "x-" + mapping.Option
+		}
+		if !strings.HasPrefix(mapping.Name, "x-") {
+			return nil, fmt.Errorf("invalid config: extension name '%s' does not start with 'x-'", mapping.Name)
+		}
+	}
+	return config, nil
+}
+
+func compileMethodPatterns(rule string, methods []string) ([]glob.Glob, error) {
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("invalid config: %s has no methods", rule)
+	}
+	patterns := make([]glob.Glob, 0, len(methods))
+	for _, method := range methods {
+		pattern, err := glob.Compile(method, '.')
+		if err != nil {
+			return nil, fmt.Errorf("invalid method glob pattern '%s': %w", method, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+func matchesMethod(patterns []glob.Glob, method protoreflect.FullName) bool {
+	return slices.ContainsFunc(patterns, func(pattern glob.Glob) bool {
+		return pattern.Match(string(method))
+	})
+}
+
+// ErrorResponseRule returns the first error response rule that matches the method, or nil.
+func (c *Config) ErrorResponseRule(method protoreflect.FullName) *ErrorResponseRule {
+	if c == nil {
+		return nil
+	}
+	for _, rule := range c.ErrorResponses {
+		if matchesMethod(rule.patterns, method) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// ErrorCodes returns the error codes that the method can return. It returns false when the codes aren't
+// narrowed down for the method.
+func (c *Config) ErrorCodes(method protoreflect.FullName) ([]string, bool) {
+	rule := c.ErrorResponseRule(method)
+	if rule == nil || len(rule.Codes) == 0 {
+		return nil, false
+	}
+	return rule.Codes, true
+}
+
+// ErrorDetails returns the names of the error detail messages that the method can return.
+func (c *Config) ErrorDetails(method protoreflect.FullName) []string {
+	if rule := c.ErrorResponseRule(method); rule != nil {
+		return rule.Details
+	}
+	return nil
+}
+
+// MethodHeaders returns the custom request and response headers of the method. A header that is declared by
+// multiple matching rules uses the declaration of the last rule.
+func (c *Config) MethodHeaders(method protoreflect.FullName) (request, response []*Header) {
+	if c == nil {
+		return nil, nil
+	}
+	for _, rule := range c.Headers {
+		if matchesMethod(rule.patterns, method) {
+			request = mergeHeaders(request, rule.Request)
+			response = mergeHeaders(response, rule.Response)
+		}
+	}
+	return request, response
+}
+
+func mergeHeaders(headers, more []*Header) []*Header {
+	for _, header := range more {
+		i := slices.IndexFunc(headers, func(h *Header) bool { return strings.EqualFold(h.Name, header.Name) })
+		if i >= 0 {
+			headers[i] = header
+		} else {
+			headers = append(headers, header)
+		}
+	}
+	return headers
+}
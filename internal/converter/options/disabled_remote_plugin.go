@@ -11,4 +11,5 @@ See here for more information: https://github.com/sudorandom/protoc-gen-connect-
 var disabledOptions = map[string]string{
 	"base":     fmt.Sprintf(optionDisabledRemotePlugin, "base"),
 	"override": fmt.Sprintf(optionDisabledRemotePlugin, "override"),
+	"config":   fmt.Sprintf(optionDisabledRemotePlugin, "config"),
 }
@@ -4,15 +4,25 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"mime"
 	"os"
 	"path"
 	"strings"
+	"sync"
 
 	"github.com/gobwas/glob"
 	"google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/reflect/protoregistry"
 )
 
+// Formats of the responses of server-streaming google.api.http methods.
+const (
+	// RESTStreamFormatNDJSON is newline-delimited JSON, as served by grpc-gateway and Vanguard.
+	RESTStreamFormatNDJSON = "ndjson"
+	// RESTStreamFormatSSE is server-sent events with one JSON message per event.
+	RESTStreamFormatSSE = "sse"
+)
+
 type Feature string
 
 const (
@@ -21,21 +31,30 @@ const (
 	FeatureTwirp         Feature = "twirp"
 	FeatureGnostic       Feature = "gnostic"
 	FeatureProtovalidate Feature = "protovalidate"
+	FeaturePGV           Feature = "pgv"
 )
 
 type Options struct {
 	// Format is either 'yaml' or 'json' and is the format of the output OpenAPI file(s).
 	Format string
+	// OpenAPIVersion is the version of the generated OpenAPI documents, either '3.1.0' (the default) or '3.2.0'.
+	OpenAPIVersion string
 	// BaseOpenAPI is the file contents of a base OpenAPI file.
 	BaseOpenAPI []byte
 	// OverrideOpenAPI is the file contents of an override OpenAPI file.
 	OverrideOpenAPI []byte
 	// WithStreaming will content types related to streaming (warning: can be messy).
 	WithStreaming bool
+	// RESTStreamFormats are the formats of the responses of server-streaming google.api.http methods, `ndjson`
+	// and/or `sse`. Defaults to `ndjson`.
+	RESTStreamFormats []string
 	// AllowGET will let methods with `idempotency_level = NO_SIDE_EFFECTS` to be documented with GET requests.
 	AllowGET bool
 	// ContentTypes is a map of all content types. Available values are in Protocols.
 	ContentTypes map[string]struct{}
+	// HTTPBodyContentTypes are the media types used for google.api.HttpBody request and response bodies of
+	// google.api.http methods that don't declare their own. Defaults to `*/*`.
+	HTTPBodyContentTypes []string
 	// Debug enables debug logging if set to true.
 	Debug bool
 	// IncludeNumberEnumValues indicates if numbers are included for enum values in addition to the string representations.
@@ -46,6 +65,9 @@ type Options struct {
 	Path string
 	// PathPrefix is a prefix that is prepended to every HTTP path.
 	PathPrefix string
+	// TwirpPrefix is the prefix of Twirp routes, before the service name. Defaults to `/twirp`; Twirp v7 servers
+	// can use another prefix or none.
+	TwirpPrefix string
 	// TrimUnusedTypes will remove types that aren't referenced by a service.
 	TrimUnusedTypes bool
 	// WithProtoAnnotations will add some protobuf annotations for descriptions
@@ -69,22 +91,101 @@ type Options struct {
 	ShortOperationIds bool
 	// WithGoogleErrorDetail will add google error detail to the connect error response.
 	WithGoogleErrorDetail bool
+	// WithErrorResponses adds a response for each HTTP status that Connect maps error codes to.
+	WithErrorResponses bool
+	// WithProtocolHeaders documents the compression, trailer and gRPC headers of the Connect and gRPC protocols.
+	WithProtocolHeaders bool
+	// WithProtovalidateExtension adds the resolved protovalidate rules of messages and fields as an
+	// `x-protovalidate` extension instead of describing them in the description.
+	WithProtovalidateExtension bool
+	// Config is the parsed file given with the `config` option.
+	Config *Config
 	// DisableDefaultResponse disables the default 200 response.
 	DisableDefaultResponse bool
 	// EnabledFeatures is a map of enabled features.
 	EnabledFeatures map[Feature]bool
 	// AllowedVisibilities is a map of visibility strings to include. If an element has a `google.api.visibility` rule with a `restriction` that is not in this map, it will be excluded.
 	AllowedVisibilities map[string]bool
+	// VisibilityAudiences generates the files once for each of these visibility labels, as if it was the only
+	// allowed visibility. The files of each audience get the lowercase label as a suffix.
+	VisibilityAudiences []string
+	// WithInputSchemas generates separate `{name}.input` schemas for request messages that accept everything
+	// protojson accepts when unmarshalling (both field names, enum numbers). The regular schemas then describe
+	// exactly what protojson emits when marshalling.
+	WithInputSchemas bool
+	// UseEnumNumbers mirrors protojson.MarshalOptions.UseEnumNumbers for output schemas. Implies WithInputSchemas.
+	UseEnumNumbers bool
+	// EmitUnpopulated mirrors protojson.MarshalOptions.EmitUnpopulated for output schemas. Implies WithInputSchemas.
+	EmitUnpopulated bool
+	// WithRequestSchemas generates request-specific `{name}.create` schemas without OUTPUT_ONLY fields and
+	// `{name}.update` schemas for update methods that also exclude IMMUTABLE fields.
+	WithRequestSchemas bool
+
+	// SchemaVariant is the variant of message schemas currently being generated. This is set internally while
+	// generating request schemas and is not configurable.
+	SchemaVariant SchemaVariant
 
 	MessageAnnotator        MessageAnnotator
 	FieldAnnotator          FieldAnnotator
 	FieldReferenceAnnotator FieldReferenceAnnotator
 
 	ExtensionTypeResolver protoregistry.ExtensionTypeResolver
+	// Files are all files of the generation request, used to look up messages by name.
+	Files *protoregistry.Files
+	// FieldBehaviorCache caches which messages reach fields with a given field behavior. It is created for
+	// each generation run, so results never leak between runs or option sets.
+	FieldBehaviorCache *sync.Map
 
 	Logger *slog.Logger
 }
 
+// SchemaVariant describes how a message schema differs from the regular (output) schema of the message.
+type SchemaVariant struct {
+	// Input is set for schemas that describe what protojson accepts when unmarshalling.
+	Input bool
+	// StripOutputOnly removes fields with the OUTPUT_ONLY field behavior.
+	StripOutputOnly bool
+	// StripImmutable removes fields with the IMMUTABLE field behavior, in addition to OUTPUT_ONLY fields.
+	StripImmutable bool
+}
+
+// IsZero reports whether this is the regular schema variant.
+func (v SchemaVariant) IsZero() bool {
+	return v == SchemaVariant{}
+}
+
+// Suffix returns the suffix appended to component names for this schema variant.
+func (v SchemaVariant) Suffix() string {
+	suffix := ""
+	if v.Input {
+		suffix += ".input"
+	}
+	switch {
+	case v.StripImmutable:
+		suffix += ".update"
+	case v.StripOutputOnly:
+		suffix += ".create"
+	}
+	return suffix
+}
+
+// WithSchemaVariant returns a copy of the options that generates the given schema variant.
+func (opts Options) WithSchemaVariant(variant SchemaVariant) Options {
+	opts.SchemaVariant = variant
+	return opts
+}
+
+// IsOutputSchema reports whether schemas should exactly describe protojson marshalling output. This is only
+// the case when separate input schemas are generated for requests.
+func (opts Options) IsOutputSchema() bool {
+	return opts.WithInputSchemas && opts.SchemaVariant.IsZero()
+}
+
+// IsOpenAPI32 reports whether OpenAPI 3.2 documents are generated.
+func (opts Options) IsOpenAPI32() bool {
+	return opts.OpenAPIVersion == "3.2.0"
+}
+
 func (opts Options) FeatureEnabled(feature Feature) bool {
 	return opts.EnabledFeatures[feature]
 }
@@ -105,7 +206,7 @@ func (opts *Options) EnableFeatures(features ...Feature) error {
 	enabledFeatures := make(map[Feature]bool)
 	for _, feature := range features {
 		switch feature {
-		case FeatureGoogleAPIHTTP, FeatureConnectRPC, FeatureTwirp, FeatureGnostic, FeatureProtovalidate:
+		case FeatureGoogleAPIHTTP, FeatureConnectRPC, FeatureTwirp, FeatureGnostic, FeatureProtovalidate, FeaturePGV:
 			enabledFeatures[feature] = true
 		default:
 			return fmt.Errorf("invalid feature: '%s'", feature)
@@ -121,6 +222,8 @@ func NewOptions() Options {
 		ContentTypes: map[string]struct{}{
 			"json": {},
 		},
+		RESTStreamFormats: []string{RESTStreamFormatNDJSON},
+		TwirpPrefix:       "/twirp",
 		EnabledFeatures: map[Feature]bool{
 			FeatureConnectRPC:    true,
 			FeatureGoogleAPIHTTP: true,
@@ -180,8 +283,24 @@ func FromString(s string) (Options, error) {
 			opts.ShortOperationIds = true
 		case param == "with-google-error-detail":
 			opts.WithGoogleErrorDetail = true
+		case param == "with-error-responses":
+			opts.WithErrorResponses = true
+		case param == "with-protocol-headers":
+			opts.WithProtocolHeaders = true
+		case param == "with-protovalidate-extension":
+			opts.WithProtovalidateExtension = true
 		case param == "disable-default-response":
 			opts.DisableDefaultResponse = true
+		case param == "with-input-schemas":
+			opts.WithInputSchemas = true
+		case param == "with-request-schemas":
+			opts.WithRequestSchemas = true
+		case param == "use-enum-numbers":
+			opts.UseEnumNumbers = true
+			opts.WithInputSchemas = true
+		case param == "emit-unpopulated":
+			opts.EmitUnpopulated = true
+			opts.WithInputSchemas = true
 		case strings.HasPrefix(param, "features="):
 			allFeatures := []Feature{}
 			for feature := range strings.SplitSeq(param[9:], ";") {
@@ -202,10 +321,29 @@ func FromString(s string) (Options, error) {
 				}
 				contentTypes[contentType] = struct{}{}
 			}
+		case strings.HasPrefix(param, "http-body-content-types="):
+			for _, contentType := range strings.Split(param[24:], ";") {
+				contentType = strings.TrimSpace(contentType)
+				if _, _, err := mime.ParseMediaType(contentType); err != nil {
+					return opts, fmt.Errorf("invalid http body content type: '%s'", contentType)
+				}
+				opts.HTTPBodyContentTypes = append(opts.HTTPBodyContentTypes, contentType)
+			}
+		case strings.HasPrefix(param, "rest-stream-formats="):
+			opts.RESTStreamFormats = nil
+			for _, format := range strings.Split(param[20:], ";") {
+				format = strings.TrimSpace(format)
+				if format != RESTStreamFormatNDJSON && format != RESTStreamFormatSSE {
+					return opts, fmt.Errorf("invalid rest stream format: '%s'", format)
+				}
+				opts.RESTStreamFormats = append(opts.RESTStreamFormats, format)
+			}
 		case strings.HasPrefix(param, "path="):
 			opts.Path = param[5:]
 		case strings.HasPrefix(param, "path-prefix="):
 			opts.PathPrefix = param[12:]
+		case strings.HasPrefix(param, "twirp-prefix="):
+			opts.TwirpPrefix = param[13:]
 		case strings.HasPrefix(param, "format="):
 			format := param[7:]
 			switch format {
@@ -216,6 +354,16 @@ func FromString(s string) (Options, error) {
 			default:
 				return opts, fmt.Errorf("format be yaml or json, not '%s'", format)
 			}
+		case strings.HasPrefix(param, "openapi-version="):
+			version := param[16:]
+			switch version {
+			case "3.1", "3.1.0":
+				opts.OpenAPIVersion = "3.1.0"
+			case "3.2", "3.2.0":
+				opts.OpenAPIVersion = "3.2.0"
+			default:
+				return opts, fmt.Errorf("openapi-version must be 3.1 or 3.2, not '%s'", version)
+			}
 		case strings.HasPrefix(param, "base="):
 			if msg, ok := disabledOptions["base"]; ok {
 				return opts, errors.New(msg)
@@ -248,6 +396,26 @@ func FromString(s string) (Options, error) {
 			default:
 				return opts, fmt.Errorf("the file extension for 'override' should end with yaml or json, not '%s'", ext)
 			}
+		case strings.HasPrefix(param, "config="):
+			if msg, ok := disabledOptions["config"]; ok {
+				return opts, errors.New(msg)
+			}
+			configPath := param[7:]
+			ext := path.Ext(configPath)
+			switch ext {
+			case ".yaml", ".yml", ".json":
+				body, err := os.ReadFile(configPath)
+				if err != nil {
+					return opts, err
+				}
+				config, err := ParseConfig(body)
+				if err != nil {
+					return opts, err
+				}
+				opts.Config = config
+			default:
+				return opts, fmt.Errorf("the file extension for 'config' should end with yaml or json, not '%s'", ext)
+			}
 		case strings.HasPrefix(param, "services="):
 			services := strings.Split(param[9:], ",")
 			patterns, err := CompileServicePatterns(services)
@@ -261,6 +429,15 @@ func FromString(s string) (Options, error) {
 			for _, selector := range selectors {
 				opts.AllowedVisibilities[selector] = true
 			}
+		case strings.HasPrefix(param, "visibility-audiences="):
+			opts.VisibilityAudiences = nil
+			for audience := range strings.SplitSeq(param[len("visibility-audiences="):], ";") {
+				audience = strings.TrimSpace(audience)
+				if audience == "" {
+					return opts, fmt.Errorf("visibility-audiences has an empty audience in '%s'", param[len("visibility-audiences="):])
+				}
+				opts.VisibilityAudiences = append(opts.VisibilityAudiences, audience)
+			}
 		default:
 			return opts, fmt.Errorf("invalid parameter: %s", param)
 		}
@@ -278,6 +455,9 @@ func FromString(s string) (Options, error) {
 		opts.EnabledFeatures[FeatureTwirp] = false
 		opts.EnabledFeatures[FeatureGoogleAPIHTTP] = true
 	}
+	if opts.AllowedVisibilities != nil && len(opts.VisibilityAudiences) > 0 {
+		return opts, errors.New("allowed-visibilities and visibility-audiences can't be used together, each audience is generated with its label as the only allowed visibility")
+	}
 	opts.Logger.Debug("Enabled features before final check", "features", opts.EnabledFeatures)
 	hasProtocolFeature := opts.FeatureEnabled(FeatureConnectRPC) || opts.FeatureEnabled(FeatureGoogleAPIHTTP) || opts.FeatureEnabled(FeatureTwirp)
 	if !hasProtocolFeature {
@@ -1,6 +1,8 @@
 package options_test
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -27,6 +29,12 @@ func TestFromString(t *testing.T) {
 			"short-service-tags",
 			"short-operation-ids",
 			"with-google-error-detail",
+			"with-input-schemas",
+			"use-enum-numbers",
+			"emit-unpopulated",
+			"with-request-schemas",
+			"with-protocol-headers",
+			"with-protovalidate-extension",
 		}
 		opts, err := options.FromString(strings.Join(optionList, ","))
 		require.NoError(t, err)
@@ -45,6 +53,12 @@ func TestFromString(t *testing.T) {
 		assert.True(t, opts.ShortServiceTags)
 		assert.True(t, opts.ShortOperationIds)
 		assert.True(t, opts.WithGoogleErrorDetail)
+		assert.True(t, opts.WithInputSchemas)
+		assert.True(t, opts.UseEnumNumbers)
+		assert.True(t, opts.EmitUnpopulated)
+		assert.True(t, opts.WithRequestSchemas)
+		assert.True(t, opts.WithProtocolHeaders)
+		assert.True(t, opts.WithProtovalidateExtension)
 
 		t.Run("only-googleapi-http", func(t *testing.T) {
 			opts, err := options.FromString("only-googleapi-http")
@@ -112,6 +126,13 @@ func TestFromString(t *testing.T) {
 			assert.False(t, opts.FeatureEnabled(options.FeatureTwirp))
 		})
 
+		t.Run("pgv", func(t *testing.T) {
+			opts, err := options.FromString("features=connectrpc;pgv")
+			require.NoError(t, err)
+			assert.True(t, opts.FeatureEnabled(options.FeaturePGV))
+			assert.False(t, opts.FeatureEnabled(options.FeatureProtovalidate))
+		})
+
 		t.Run("invalid feature", func(t *testing.T) {
 			_, err := options.FromString("features=invalid")
 			require.Error(t, err)
@@ -169,6 +190,184 @@ func TestFromString(t *testing.T) {
 		})
 	})
 
+	t.Run("http-body-content-types", func(t *testing.T) {
+		t.Run("multiple", func(t *testing.T) {
+			opts, err := options.FromString("http-body-content-types=text/csv;image/*")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"text/csv", "image/*"}, opts.HTTPBodyContentTypes)
+		})
+		t.Run("invalid", func(t *testing.T) {
+			_, err := options.FromString("http-body-content-types=text/")
+			require.Error(t, err)
+		})
+	})
+
+	t.Run("rest-stream-formats", func(t *testing.T) {
+		t.Run("default", func(t *testing.T) {
+			opts, err := options.FromString("")
+			require.NoError(t, err)
+			assert.Equal(t, []string{options.RESTStreamFormatNDJSON}, opts.RESTStreamFormats)
+		})
+		t.Run("multiple", func(t *testing.T) {
+			opts, err := options.FromString("rest-stream-formats=sse;ndjson")
+			require.NoError(t, err)
+			assert.Equal(t, []string{options.RESTStreamFormatSSE, options.RESTStreamFormatNDJSON}, opts.RESTStreamFormats)
+		})
+		t.Run("invalid", func(t *testing.T) {
+			_, err := options.FromString("rest-stream-formats=websocket")
+			require.Error(t, err)
+		})
+	})
+
+	t.Run("openapi-version", func(t *testing.T) {
+		t.Run("default", func(t *testing.T) {
+			opts, err := options.FromString("")
+			require.NoError(t, err)
+			assert.False(t, opts.IsOpenAPI32())
+		})
+		t.Run("3.1", func(t *testing.T) {
+			opts, err := options.FromString("openapi-version=3.1")
+			require.NoError(t, err)
+			assert.Equal(t, "3.1.0", opts.OpenAPIVersion)
+			assert.False(t, opts.IsOpenAPI32())
+		})
+		t.Run("3.2", func(t *testing.T) {
+			opts, err := options.FromString("openapi-version=3.2.0")
+			require.NoError(t, err)
+			assert.Equal(t, "3.2.0", opts.OpenAPIVersion)
+			assert.True(t, opts.IsOpenAPI32())
+		})
+		t.Run("invalid", func(t *testing.T) {
+			_, err := options.FromString("openapi-version=3.0")
+			require.Error(t, err)
+		})
+	})
+
+	t.Run("config", func(t *testing.T) {
+		t.Run("error responses", func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "config.yaml")
+			require.NoError(t, os.WriteFile(configPath, []byte(`
+errorResponses:
+  - methods: ["acme.v1.PetService.Get*"]
+    codes: [not_found]
+  - methods: ["acme.v1.**"]
+    codes: [internal, unavailable]
+`), 0o644))
+			opts, err := options.FromString("with-error-responses,config=" + configPath)
+			require.NoError(t, err)
+			assert.True(t, opts.WithErrorResponses)
+			codes, ok := opts.Config.ErrorCodes("acme.v1.PetService.GetPet")
+			assert.True(t, ok)
+			assert.Equal(t, []string{"not_found"}, codes)
+			codes, ok = opts.Config.ErrorCodes("acme.v1.PetService.DeletePet")
+			assert.True(t, ok)
+			assert.Equal(t, []string{"internal", "unavailable"}, codes)
+			_, ok = opts.Config.ErrorCodes("other.v1.PetService.GetPet")
+			assert.False(t, ok)
+		})
+		t.Run("error details", func(t *testing.T) {
+			config, err := options.ParseConfig([]byte(`
+errorResponses:
+  - methods: ["acme.v1.PetService.GetPet"]
+    details: [google.rpc.ErrorInfo, acme.v1.PetNotFound]
+`))
+			require.NoError(t, err)
+			assert.Equal(t, []string{"google.rpc.ErrorInfo", "acme.v1.PetNotFound"}, config.ErrorDetails("acme.v1.PetService.GetPet"))
+			assert.Empty(t, config.ErrorDetails("acme.v1.PetService.DeletePet"))
+			_, ok := config.ErrorCodes("acme.v1.PetService.GetPet")
+			assert.False(t, ok)
+		})
+		t.Run("headers", func(t *testing.T) {
+			config, err := options.ParseConfig([]byte(`
+headers:
+  - methods: ["acme.billing.**"]
+    request:
+      - name: X-Tenant-Id
+        required: true
+    response:
+      - name: X-Request-Id
+  - methods: ["acme.billing.v1.InvoiceService.PayInvoice"]
+    request:
+      - name: x-tenant-id
+        description: Overridden
+      - name: Idempotency-Key
+`))
+			require.NoError(t, err)
+			request, response := config.MethodHeaders("acme.billing.v1.InvoiceService.PayInvoice")
+			require.Len(t, request, 2)
+			assert.Equal(t, "x-tenant-id", request[0].Name)
+			assert.Equal(t, "Overridden", request[0].Description)
+			assert.Equal(t, "Idempotency-Key", request[1].Name)
+			require.Len(t, response, 1)
+			assert.Equal(t, "X-Request-Id", response[0].Name)
+			request, response = config.MethodHeaders("acme.users.v1.UserService.GetUser")
+			assert.Empty(t, request)
+			assert.Empty(t, response)
+		})
+		t.Run("header without name", func(t *testing.T) {
+			_, err := options.ParseConfig([]byte("headers:\n  - methods: [\"**\"]\n    request:\n      - description: missing\n"))
+			require.Error(t, err)
+		})
+		t.Run("security", func(t *testing.T) {
+			config, err := options.ParseConfig([]byte(`
+securitySchemes:
+  oauth2:
+    type: oauth2
+security:
+  - methods: ["**.Public*"]
+    requirements: []
+  - option: acme.auth.v1.scopes
+    scheme: oauth2
+`))
+			require.NoError(t, err)
+			require.Len(t, config.Security, 2)
+			assert.NotNil(t, config.Security[0].Requirements)
+			assert.Empty(t, config.Security[0].Requirements)
+			assert.True(t, config.Security[0].Matches("acme.v1.PetService.PublicListPets"))
+			assert.False(t, config.Security[0].Matches("acme.v1.PetService.GetPet"))
+			assert.True(t, config.Security[1].Matches("acme.v1.PetService.GetPet"))
+			assert.False(t, config.SecuritySchemes.IsZero())
+		})
+		t.Run("security rule without requirements", func(t *testing.T) {
+			_, err := options.ParseConfig([]byte("security:\n  - methods: [\"**\"]\n"))
+			require.Error(t, err)
+			_, err = options.ParseConfig([]byte("security:\n  - option: acme.auth.v1.scopes\n"))
+			require.Error(t, err)
+		})
+		t.Run("extensions", func(t *testing.T) {
+			config, err := options.ParseConfig([]byte(`
+extensions:
+  - option: acme.api.owner
+    name: x-owner
+  - option: acme.api.sla_ms
+`))
+			require.NoError(t, err)
+			require.Len(t, config.Extensions, 2)
+			assert.Equal(t, "x-owner", config.Extensions[0].Name)
+			assert.Equal(t, "x-acme.api.sla_ms", config.Extensions[1].Name)
+			_, err = options.ParseConfig([]byte("extensions:\n  - option: acme.api.owner\n    name: owner\n"))
+			require.Error(t, err)
+		})
+		t.Run("invalid extension", func(t *testing.T) {
+			_, err := options.FromString("config=config.txt")
+			require.Error(t, err)
+		})
+		t.Run("unknown key", func(t *testing.T) {
+			_, err := options.ParseConfig([]byte("errorResponses:\n  - methods: [\"**\"]\n    code: [not_found]\n"))
+			require.ErrorContains(t, err, "field code not found")
+			_, err = options.ParseConfig([]byte("errorResponse:\n  - methods: [\"**\"]\n"))
+			require.ErrorContains(t, err, "field errorResponse not found")
+		})
+		t.Run("empty", func(t *testing.T) {
+			_, err := options.ParseConfig(nil)
+			require.NoError(t, err)
+		})
+		t.Run("rule without methods", func(t *testing.T) {
+			_, err := options.ParseConfig([]byte("errorResponses:\n  - codes: [not_found]\n"))
+			require.Error(t, err)
+		})
+	})
+
 	t.Run("path", func(t *testing.T) {
 		opts, err := options.FromString("path=/tmp/openapi.yaml")
 		require.NoError(t, err)
@@ -181,6 +380,32 @@ func TestFromString(t *testing.T) {
 		assert.Equal(t, "/api/v1", opts.PathPrefix)
 	})
 
+	t.Run("twirp-prefix", func(t *testing.T) {
+		opts, err := options.FromString("")
+		require.NoError(t, err)
+		assert.Equal(t, "/twirp", opts.TwirpPrefix)
+
+		opts, err = options.FromString("twirp-prefix=/rpc")
+		require.NoError(t, err)
+		assert.Equal(t, "/rpc", opts.TwirpPrefix)
+
+		opts, err = options.FromString("twirp-prefix=")
+		require.NoError(t, err)
+		assert.Equal(t, "", opts.TwirpPrefix)
+	})
+
+	t.Run("visibility-audiences", func(t *testing.T) {
+		opts, err := options.FromString("visibility-audiences=PUBLIC; PARTNER;INTERNAL")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"PUBLIC", "PARTNER", "INTERNAL"}, opts.VisibilityAudiences)
+
+		_, err = options.FromString("visibility-audiences=PUBLIC;;INTERNAL")
+		assert.EqualError(t, err, "visibility-audiences has an empty audience in 'PUBLIC;;INTERNAL'")
+
+		_, err = options.FromString("allowed-visibilities=PUBLIC,visibility-audiences=PUBLIC;INTERNAL")
+		assert.ErrorContains(t, err, "allowed-visibilities and visibility-audiences can't be used together")
+	})
+
 	t.Run("services", func(t *testing.T) {
 		opts, err := options.FromString("services=foo.v1.FooService,services=bar.v1.BarService")
 		require.NoError(t, err)
@@ -6,9 +6,11 @@ import (
 	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
 	"github.com/pb33f/libopenapi/orderedmap"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/connectrpc"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/extensions"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/gnostic"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/googleapi"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/options"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/schema"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/twirp"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/util"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/visibility"
@@ -22,29 +24,34 @@ func addPathItemsFromFile(opts options.Options, fd protoreflect.FileDescriptor,
 		if !opts.HasService(service.FullName()) {
 			continue
 		}
-		if visibility.ShouldBeFiltered(visibility.GetVisibilityRule(service), opts.AllowedVisibilities) {
-			opts.Logger.Debug("Filtering service due to visibility", slog.String("service", string(service.FullName())), slog.Any("restriction_selectors", opts.AllowedVisibilities))
+		if visibility.IsHidden(opts, service) {
 			continue
 		}
 		methods := service.Methods()
 		for j := 0; j < methods.Len(); j++ {
 			method := methods.Get(j)
 
-			if visibility.ShouldBeFiltered(visibility.GetVisibilityRule(method), opts.AllowedVisibilities) {
-				opts.Logger.Debug("Filtering method due to visibility", slog.String("method", string(method.FullName())), slog.Any("restriction_selectors", opts.AllowedVisibilities))
+			if visibility.IsHidden(opts, method) {
 				continue
 			}
 
 			// No matter what, we add the schemas for the method input/output
 			AddMessageSchemas(opts, method.Input(), doc)
 			AddMessageSchemas(opts, method.Output(), doc)
+			if reqOpts := schema.RequestOptions(opts, method); !reqOpts.SchemaVariant.IsZero() {
+				AddMessageSchemas(reqOpts, method.Input(), doc)
+			}
+			addErrorDetailSchemas(opts, method, doc)
 
 			// Helper function to update or set path items
 			addPathItem := func(path string, newItem *v3.PathItem, deferredParams []*v3.Parameter) {
 				if opts.FeatureEnabled(options.FeatureGnostic) {
 					newItem = gnostic.PathItemWithMethodAnnotations(opts, newItem, method)
 				}
-				for kv := newItem.GetOperations().First(); kv != nil; kv = kv.Next() {
+				addCustomHeaders(opts, method, newItem)
+				addSecurityRequirements(opts, method, newItem)
+				extensions.PathItemWithMethodOptions(opts, newItem, method)
+				for kv := util.PathItemOperations(newItem).First(); kv != nil; kv = kv.Next() {
 					for _, dp := range deferredParams {
 						for _, ep := range kv.Value().Parameters {
 							if ep.Name == dp.Name && ep.In == dp.In && ep.Description == "" {
@@ -74,7 +81,7 @@ func addPathItemsFromFile(opts options.Options, fd protoreflect.FileDescriptor,
 					}
 				}
 				if isGoogleHTTP {
-					googleapi.AddSchemas(opts, doc)
+					googleapi.AddSchemas(opts, doc, method)
 				}
 			}
 
@@ -100,6 +107,27 @@ func addPathItemsFromFile(opts options.Options, fd protoreflect.FileDescriptor,
 	return nil
 }
 
+// addErrorDetailSchemas adds the schemas of the error details that are declared for the method in the config.
+func addErrorDetailSchemas(opts options.Options, method protoreflect.MethodDescriptor, doc *v3.Document) {
+	for _, name := range opts.Config.ErrorDetails(method.FullName()) {
+		if opts.Files != nil {
+			if desc, err := opts.Files.FindDescriptorByName(protoreflect.FullName(name)); err == nil {
+				if md, ok := desc.(protoreflect.MessageDescriptor); ok {
+					AddMessageSchemas(opts, md, doc)
+					continue
+				}
+			}
+		}
+		if s, ok := connectrpc.NewGoogleRPCErrorDetailSchemas().Get(name); ok {
+			if _, exists := doc.Components.Schemas.Get(name); !exists {
+				doc.Components.Schemas.Set(name, s)
+			}
+			continue
+		}
+		opts.Logger.Warn("unknown error detail message in config", slog.String("message", name), slog.String("method", string(method.FullName())))
+	}
+}
+
 func mergePathItems(existing, new *v3.PathItem) {
 	// Merge operations
 	operations := []struct {
@@ -114,6 +142,7 @@ func mergePathItems(existing, new *v3.PathItem) {
 		{&existing.Head, new.Head},
 		{&existing.Patch, new.Patch},
 		{&existing.Trace, new.Trace},
+		{&existing.Query, new.Query},
 	}
 
 	for _, op := range operations {
@@ -121,6 +150,14 @@ func mergePathItems(existing, new *v3.PathItem) {
 			mergeOperation(op.existingOp, op.newOp)
 		}
 	}
+	for pair := new.AdditionalOperations.First(); pair != nil; pair = pair.Next() {
+		if existing.AdditionalOperations == nil {
+			existing.AdditionalOperations = orderedmap.New[string, *v3.Operation]()
+		}
+		existingOp := existing.AdditionalOperations.GetOrZero(pair.Key())
+		mergeOperation(&existingOp, pair.Value())
+		existing.AdditionalOperations.Set(pair.Key(), existingOp)
+	}
 
 	// Merge other fields
 	if new.Summary != "" {
@@ -0,0 +1,110 @@
+package pgv
+
+import (
+	"buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	pgv "github.com/envoyproxy/protoc-gen-validate/validate"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// fieldRules returns the protoc-gen-validate rules of the field, or nil if it has none.
+func fieldRules(desc protoreflect.FieldDescriptor) *pgv.FieldRules {
+	if desc.Options() == nil || !proto.HasExtension(desc.Options(), pgv.E_Rules) {
+		return nil
+	}
+	rules, _ := proto.GetExtension(desc.Options(), pgv.E_Rules).(*pgv.FieldRules)
+	return rules
+}
+
+// isDisabled returns whether protoc-gen-validate skips the validation of the message.
+func isDisabled(desc protoreflect.MessageDescriptor) bool {
+	if desc == nil || desc.Options() == nil {
+		return false
+	}
+	disabled, _ := proto.GetExtension(desc.Options(), pgv.E_Disabled).(bool)
+	ignored, _ := proto.GetExtension(desc.Options(), pgv.E_Ignored).(bool)
+	return disabled || ignored
+}
+
+// IsOneofRequired returns whether `(validate.required)` requires one of the fields of the oneof to be set.
+func IsOneofRequired(desc protoreflect.OneofDescriptor) bool {
+	if desc.Options() == nil || isDisabled(desc.Parent().(protoreflect.MessageDescriptor)) {
+		return false
+	}
+	required, _ := proto.GetExtension(desc.Options(), pgv.E_Required).(bool)
+	return required
+}
+
+// convertFieldRules converts protoc-gen-validate rules to the protovalidate rules they were the basis for. The
+// rules of both libraries mostly share their names and types, so the rules are copied by name. The few rules
+// that protovalidate moved to the field rules, like `required` and `ignore_empty`, are moved here too.
+func convertFieldRules(rules *pgv.FieldRules) *validate.FieldRules {
+	if rules == nil {
+		return nil
+	}
+	result := &validate.FieldRules{}
+	if message := rules.GetMessage(); message != nil {
+		if message.GetRequired() {
+			result.Required = proto.Bool(true)
+		}
+		if message.GetSkip() {
+			result.Ignore = validate.Ignore_IGNORE_ALWAYS.Enum()
+		}
+	}
+
+	src := rules.ProtoReflect()
+	typeOneof := src.Descriptor().Oneofs().ByName("type")
+	typeField := src.WhichOneof(typeOneof)
+	if typeField == nil {
+		return result
+	}
+	dst := result.ProtoReflect()
+	dstField := dst.Descriptor().Fields().ByName(typeField.Name())
+	if dstField == nil {
+		return result
+	}
+	typeRules := dst.Mutable(dstField).Message()
+	src.Get(typeField).Message().Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch fd.Name() {
+		case "ignore_empty":
+			if v.Bool() && result.Ignore == nil {
+				result.Ignore = validate.Ignore_IGNORE_IF_ZERO_VALUE.Enum()
+			}
+			return true
+		case "required":
+			if v.Bool() {
+				result.Required = proto.Bool(true)
+			}
+			return true
+		}
+		if target := typeRules.Descriptor().Fields().ByName(fd.Name()); target != nil {
+			copyRule(typeRules, target, fd, v)
+		}
+		return true
+	})
+	return result
+}
+
+// copyRule sets the rule of src on dst, when both have the same type. Nested field rules, like the rules of the
+// items of a repeated field, are converted.
+func copyRule(dst protoreflect.Message, dstField, srcField protoreflect.FieldDescriptor, v protoreflect.Value) {
+	if dstField.Kind() != srcField.Kind() || dstField.Cardinality() != srcField.Cardinality() {
+		return
+	}
+	if srcField.Kind() == protoreflect.MessageKind && dstField.Message().FullName() != srcField.Message().FullName() {
+		nested, ok := v.Message().Interface().(*pgv.FieldRules)
+		if !ok || srcField.IsList() {
+			return
+		}
+		dst.Set(dstField, protoreflect.ValueOfMessage(convertFieldRules(nested).ProtoReflect()))
+		return
+	}
+	if !srcField.IsList() {
+		dst.Set(dstField, v)
+		return
+	}
+	list := dst.Mutable(dstField).List()
+	for i := 0; i < v.List().Len(); i++ {
+		list.Append(v.List().Get(i))
+	}
+}
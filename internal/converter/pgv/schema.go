@@ -0,0 +1,30 @@
+package pgv
+
+import (
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/options"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/protovalidate"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// SchemaWithFieldAnnotations applies the protoc-gen-validate (`validate.rules`) rules of the field to its schema.
+// The rules are converted to protovalidate rules, so both produce the same schema keywords.
+func SchemaWithFieldAnnotations(opts options.Options, schema *base.Schema, desc protoreflect.FieldDescriptor, onlyScalar bool) *base.Schema {
+	if isDisabled(desc.ContainingMessage()) {
+		return schema
+	}
+	rules := convertFieldRules(fieldRules(desc))
+	if rules == nil {
+		return schema
+	}
+	return protovalidate.SchemaWithFieldRules(opts, schema, desc, rules, onlyScalar)
+}
+
+// PopulateParentProperties marks the field as required in the schema of its message when its protoc-gen-validate
+// rules require it.
+func PopulateParentProperties(opts options.Options, parent *base.Schema, desc protoreflect.FieldDescriptor) *base.Schema {
+	if parent == nil || isDisabled(desc.ContainingMessage()) {
+		return parent
+	}
+	return protovalidate.ParentPropertiesWithFieldRules(opts, parent, desc, convertFieldRules(fieldRules(desc)))
+}
@@ -0,0 +1,399 @@
+package protovalidate
+
+import (
+	"fmt"
+	"reflect"
+
+	"buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/parser"
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+	"github.com/pb33f/libopenapi/orderedmap"
+	"github.com/pb33f/libopenapi/utils"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/options"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/util"
+	"go.yaml.in/yaml/v4"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// celConstraint is a JSON schema translation of (a part of) a CEL expression.
+type celConstraint struct {
+	// field is the field of the message that is constrained, or nil when the expression constrains `this`.
+	field protoreflect.FieldDescriptor
+	// required is set for `has(this.field)`.
+	required bool
+	schema   *base.Schema
+}
+
+// celTranslator translates common CEL expressions of protovalidate rules into JSON schema keywords. The
+// subject of field rules is the field, the subject of message rules is the message.
+type celTranslator struct {
+	field   protoreflect.FieldDescriptor
+	message protoreflect.MessageDescriptor
+	// item is set when the subject of the rules is an item of the repeated field.
+	item bool
+}
+
+// translate returns the JSON schema constraints that are equivalent to the expression. It returns false when
+// the expression, or a part of it, can't be translated.
+func (t celTranslator) translate(expression string) ([]celConstraint, bool) {
+	p, err := parser.NewParser(parser.Macros(parser.AllMacros...))
+	if err != nil {
+		return nil, false
+	}
+	parsed, errs := p.Parse(common.NewTextSource(expression))
+	if len(errs.GetErrors()) > 0 {
+		return nil, false
+	}
+	return t.translateExpr(parsed.Expr())
+}
+
+func (t celTranslator) translateExpr(e ast.Expr) ([]celConstraint, bool) {
+	switch e.Kind() {
+	case ast.SelectKind:
+		// has(this.field) is expanded to a test-only select.
+		sel := e.AsSelect()
+		if !sel.IsTestOnly() || !isThis(sel.Operand()) || t.message == nil {
+			return nil, false
+		}
+		fd := t.message.Fields().ByName(protoreflect.Name(sel.FieldName()))
+		if fd == nil {
+			return nil, false
+		}
+		return []celConstraint{{field: fd, required: true}}, true
+	case ast.CallKind:
+	default:
+		return nil, false
+	}
+
+	call := e.AsCall()
+	args := call.Args()
+	switch call.FunctionName() {
+	case operators.LogicalAnd:
+		var result []celConstraint
+		for _, arg := range args {
+			constraints, ok := t.translateExpr(arg)
+			if !ok {
+				return nil, false
+			}
+			result = append(result, constraints...)
+		}
+		return result, true
+	case operators.Less, operators.LessEquals, operators.Greater, operators.GreaterEquals, operators.Equals:
+		if len(args) != 2 {
+			return nil, false
+		}
+		op, left, right := call.FunctionName(), args[0], args[1]
+		if left.Kind() == ast.LiteralKind {
+			op, left, right = flipComparison(op), right, left
+		}
+		n, ok := numberLiteral(right)
+		if !ok {
+			return nil, false
+		}
+		if subject, ok := sizeSubject(left); ok {
+			fd, ok := t.subject(subject)
+			if !ok {
+				return nil, false
+			}
+			return t.sizeConstraint(fd, op, n)
+		}
+		fd, ok := t.subject(left)
+		if !ok {
+			return nil, false
+		}
+		return t.boundConstraint(fd, op, n)
+	case operators.In:
+		if len(args) != 2 || args[1].Kind() != ast.ListKind {
+			return nil, false
+		}
+		fd, ok := t.subject(args[0])
+		if !ok || t.isList(fd) || fd.IsMap() || !isInListKind(fd.Kind()) {
+			return nil, false
+		}
+		s := &base.Schema{}
+		for _, element := range args[1].AsList().Elements() {
+			node, ok := literalNode(element)
+			if !ok {
+				return nil, false
+			}
+			s.Enum = append(s.Enum, node)
+		}
+		return t.constraint(fd, s), true
+	case "matches":
+		var subject, pattern ast.Expr
+		switch {
+		case call.IsMemberFunction() && len(args) == 1:
+			subject, pattern = call.Target(), args[0]
+		case !call.IsMemberFunction() && len(args) == 2:
+			subject, pattern = args[0], args[1]
+		default:
+			return nil, false
+		}
+		fd, ok := t.subject(subject)
+		if !ok || t.isList(fd) || fd.IsMap() || fd.Kind() != protoreflect.StringKind || pattern.Kind() != ast.LiteralKind {
+			return nil, false
+		}
+		value, ok := pattern.AsLiteral().(types.String)
+		if !ok {
+			return nil, false
+		}
+		return t.constraint(fd, &base.Schema{Pattern: string(value)}), true
+	}
+	return nil, false
+}
+
+// subject returns the field that the expression refers to: `this` for field rules or `this.field` for
+// message rules.
+func (t celTranslator) subject(e ast.Expr) (protoreflect.FieldDescriptor, bool) {
+	if isThis(e) && t.field != nil {
+		return t.field, true
+	}
+	if e.Kind() != ast.SelectKind || t.message == nil {
+		return nil, false
+	}
+	sel := e.AsSelect()
+	if sel.IsTestOnly() || !isThis(sel.Operand()) {
+		return nil, false
+	}
+	fd := t.message.Fields().ByName(protoreflect.Name(sel.FieldName()))
+	return fd, fd != nil
+}
+
+// isList returns whether the subject is a list, which an item of a repeated field isn't.
+func (t celTranslator) isList(fd protoreflect.FieldDescriptor) bool {
+	return fd.IsList() && !(t.item && fd == t.field)
+}
+
+// constraint returns a constraint on the field, which is `this` for field rules.
+func (t celTranslator) constraint(fd protoreflect.FieldDescriptor, s *base.Schema) []celConstraint {
+	if t.field != nil {
+		fd = nil
+	}
+	return []celConstraint{{field: fd, schema: s}}
+}
+
+func (t celTranslator) sizeConstraint(fd protoreflect.FieldDescriptor, op string, n float64) ([]celConstraint, bool) {
+	minimum, maximum, ok := integerBounds(op, n)
+	if !ok {
+		return nil, false
+	}
+	s := &base.Schema{}
+	switch {
+	case fd.IsMap():
+		s.MinProperties, s.MaxProperties = minimum, maximum
+	case t.isList(fd):
+		s.MinItems, s.MaxItems = minimum, maximum
+	case fd.Kind() == protoreflect.StringKind:
+		s.MinLength, s.MaxLength = minimum, maximum
+	default:
+		// The size of bytes doesn't match the length of their base64 encoding.
+		return nil, false
+	}
+	return t.constraint(fd, s), true
+}
+
+func (t celTranslator) boundConstraint(fd protoreflect.FieldDescriptor, op string, n float64) ([]celConstraint, bool) {
+	if t.isList(fd) || fd.IsMap() || !isNumeric(fd.Kind()) {
+		return nil, false
+	}
+	s := &base.Schema{}
+	switch op {
+	case operators.Less:
+		s.ExclusiveMaximum = &base.DynamicValue[bool, float64]{N: 1, B: n}
+	case operators.LessEquals:
+		s.Maximum = &n
+	case operators.Greater:
+		s.ExclusiveMinimum = &base.DynamicValue[bool, float64]{N: 1, B: n}
+	case operators.GreaterEquals:
+		s.Minimum = &n
+	default:
+		return nil, false
+	}
+	return t.constraint(fd, s), true
+}
+
+// integerBounds returns the inclusive bounds of an integer that is compared with n.
+func integerBounds(op string, n float64) (minimum, maximum *int64, ok bool) {
+	if n != float64(int64(n)) {
+		return nil, nil, false
+	}
+	v := int64(n)
+	switch op {
+	case operators.Less:
+		v--
+		return nil, &v, true
+	case operators.LessEquals:
+		return nil, &v, true
+	case operators.Greater:
+		v++
+		return &v, nil, true
+	case operators.GreaterEquals:
+		return &v, nil, true
+	case operators.Equals:
+		other := v
+		return &v, &other, true
+	}
+	return nil, nil, false
+}
+
+func flipComparison(op string) string {
+	switch op {
+	case operators.Less:
+		return operators.Greater
+	case operators.LessEquals:
+		return operators.GreaterEquals
+	case operators.Greater:
+		return operators.Less
+	case operators.GreaterEquals:
+		return operators.LessEquals
+	}
+	return op
+}
+
+func isThis(e ast.Expr) bool {
+	return e.Kind() == ast.IdentKind && e.AsIdent() == "this"
+}
+
+// sizeSubject returns the argument of `size(x)` or `x.size()`.
+func sizeSubject(e ast.Expr) (ast.Expr, bool) {
+	if e.Kind() != ast.CallKind || e.AsCall().FunctionName() != "size" {
+		return nil, false
+	}
+	call := e.AsCall()
+	switch {
+	case call.IsMemberFunction() && len(call.Args()) == 0:
+		return call.Target(), true
+	case !call.IsMemberFunction() && len(call.Args()) == 1:
+		return call.Args()[0], true
+	}
+	return nil, false
+}
+
+func numberLiteral(e ast.Expr) (float64, bool) {
+	if e.Kind() != ast.LiteralKind {
+		return 0, false
+	}
+	switch v := e.AsLiteral().(type) {
+	case types.Int:
+		return float64(v), true
+	case types.Uint:
+		return float64(v), true
+	case types.Double:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func literalNode(e ast.Expr) (*yaml.Node, bool) {
+	if e.Kind() != ast.LiteralKind {
+		return nil, false
+	}
+	switch v := e.AsLiteral().(type) {
+	case types.String:
+		return utils.CreateStringNode(string(v)), true
+	case types.Int, types.Uint:
+		return utils.CreateIntNode(fmt.Sprint(v.Value())), true
+	case types.Double:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: fmt.Sprint(v.Value())}, true
+	case types.Bool:
+		return utils.CreateBoolNode(fmt.Sprint(v.Value())), true
+	}
+	return nil, false
+}
+
+func isNumeric(kind protoreflect.Kind) bool {
+	switch kind {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint32Kind, protoreflect.Fixed32Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind,
+		protoreflect.FloatKind, protoreflect.DoubleKind:
+		return true
+	}
+	return false
+}
+
+// isInListKind returns whether the JSON value of a field of the kind can be compared with the literals of an
+// `in` list. Enums are encoded as names and bytes as base64, so their lists stay as rules.
+func isInListKind(kind protoreflect.Kind) bool {
+	return kind == protoreflect.StringKind || kind == protoreflect.BoolKind || isNumeric(kind)
+}
+
+// applyCELConstraints applies translated constraints to the schema. Keywords that the schema already has are
+// added with `allOf` instead, so both apply.
+func applyCELConstraints(opts options.Options, schema *base.Schema, constraints []celConstraint) {
+	properties := orderedmap.New[string, *base.SchemaProxy]()
+	for _, c := range constraints {
+		if c.field == nil {
+			mergeKeywords(schema, c.schema)
+			continue
+		}
+		name := util.MakeFieldName(opts, c.field)
+		if c.required {
+			schema.Required = util.AppendStringDedupe(schema.Required, name)
+			continue
+		}
+		if existing, ok := properties.Get(name); ok {
+			mergeKeywords(existing.Schema(), c.schema)
+		} else {
+			properties.Set(name, base.CreateSchemaProxy(c.schema))
+		}
+	}
+	if properties.Len() > 0 {
+		schema.AllOf = append(schema.AllOf, base.CreateSchemaProxy(&base.Schema{Properties: properties}))
+	}
+}
+
+// mergeKeywords sets the keywords of src on dst. Keywords that dst already has with a different value are
+// added in an `allOf` of dst instead.
+func mergeKeywords(dst, src *base.Schema) {
+	conflicts := &base.Schema{}
+	hasConflicts := false
+	merge := func(dstField, srcField, conflictField any) {
+		d, s, c := reflect.ValueOf(dstField).Elem(), reflect.ValueOf(srcField).Elem(), reflect.ValueOf(conflictField).Elem()
+		switch {
+		case s.IsZero():
+		case d.IsZero():
+			d.Set(s)
+		case !reflect.DeepEqual(d.Interface(), s.Interface()):
+			c.Set(s)
+			hasConflicts = true
+		}
+	}
+	merge(&dst.MinLength, &src.MinLength, &conflicts.MinLength)
+	merge(&dst.MaxLength, &src.MaxLength, &conflicts.MaxLength)
+	merge(&dst.MinItems, &src.MinItems, &conflicts.MinItems)
+	merge(&dst.MaxItems, &src.MaxItems, &conflicts.MaxItems)
+	merge(&dst.MinProperties, &src.MinProperties, &conflicts.MinProperties)
+	merge(&dst.MaxProperties, &src.MaxProperties, &conflicts.MaxProperties)
+	merge(&dst.Minimum, &src.Minimum, &conflicts.Minimum)
+	merge(&dst.Maximum, &src.Maximum, &conflicts.Maximum)
+	merge(&dst.ExclusiveMinimum, &src.ExclusiveMinimum, &conflicts.ExclusiveMinimum)
+	merge(&dst.ExclusiveMaximum, &src.ExclusiveMaximum, &conflicts.ExclusiveMaximum)
+	merge(&dst.Pattern, &src.Pattern, &conflicts.Pattern)
+	merge(&dst.Enum, &src.Enum, &conflicts.Enum)
+	if hasConflicts {
+		dst.AllOf = append(dst.AllOf, base.CreateSchemaProxy(conflicts))
+	}
+}
+
+// celRulesExtension returns the `x-cel-rules` extension, which lists the CEL rules for tooling.
+func celRulesExtension(rules []*validate.Rule) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	for _, rule := range rules {
+		item := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		if rule.HasId() {
+			item.Content = append(item.Content, utils.CreateStringNode("id"), utils.CreateStringNode(rule.GetId()))
+		}
+		if rule.HasMessage() {
+			item.Content = append(item.Content, utils.CreateStringNode("message"), utils.CreateStringNode(rule.GetMessage()))
+		}
+		item.Content = append(item.Content, utils.CreateStringNode("expression"), utils.CreateStringNode(rule.GetExpression()))
+		node.Content = append(node.Content, item)
+	}
+	return node
+}
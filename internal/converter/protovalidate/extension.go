@@ -0,0 +1,52 @@
+package protovalidate
+
+import (
+	"log/slog"
+
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+	"github.com/pb33f/libopenapi/orderedmap"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/options"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/util"
+	"go.yaml.in/yaml/v4"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// addRulesExtension adds the rules to the schema as the `x-protovalidate` extension, rendered the way
+// protojson renders them. Predefined rules are extensions of the rules, so they're parsed again with the
+// extension types of the input files.
+func addRulesExtension(opts options.Options, schema *base.Schema, rules proto.Message) {
+	if !opts.WithProtovalidateExtension || !rules.ProtoReflect().IsValid() {
+		return
+	}
+	b, err := proto.Marshal(rules)
+	if err != nil || len(b) == 0 {
+		return
+	}
+	parsed := rules.ProtoReflect().New().Interface()
+	if err := (proto.UnmarshalOptions{Resolver: opts.GetExtensionTypeResolver()}).Unmarshal(b, parsed); err != nil {
+		opts.Logger.Warn("unable to parse protovalidate rules", slog.Any("error", err))
+		return
+	}
+	marshalOptions := protojson.MarshalOptions{}
+	if resolver, ok := opts.GetExtensionTypeResolver().(interface {
+		protoregistry.ExtensionTypeResolver
+		protoregistry.MessageTypeResolver
+	}); ok {
+		marshalOptions.Resolver = resolver
+	}
+	j, err := marshalOptions.Marshal(parsed)
+	if err != nil {
+		opts.Logger.Warn("unable to render protovalidate rules", slog.Any("error", err))
+		return
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(j, &node); err != nil || len(node.Content) == 0 {
+		return
+	}
+	if schema.Extensions == nil {
+		schema.Extensions = orderedmap.New[string, *yaml.Node]()
+	}
+	schema.Extensions.Set(util.ProtovalidateExtension, util.ResetNodeStyle(node.Content[0]))
+}
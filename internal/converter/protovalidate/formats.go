@@ -0,0 +1,96 @@
+package protovalidate
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+)
+
+// Regular expressions for the well-known string rules that JSON schema has no format for. They're written in
+// the subset of syntax that both ECMA-262 and RE2 support.
+var (
+	ipv4Regex      = `(25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])(\.(25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])){3}`
+	ipv6Regex      = newIPv6Regex()
+	hostnameRegex  = `[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*\.?`
+	portRegex      = `(6553[0-5]|655[0-2][0-9]|65[0-4][0-9]{2}|6[0-4][0-9]{3}|[1-5][0-9]{4}|[1-9][0-9]{0,3}|0)`
+	ipv4PrefixLen  = `/(3[0-2]|[12]?[0-9])`
+	ipv6PrefixLen  = `/(12[0-8]|1[01][0-9]|[1-9]?[0-9])`
+	tuuidRegex     = `^[0-9a-fA-F]{32}$`
+	ulidRegex      = `^[0-7][0-9A-HJKMNP-TV-Za-hjkmnp-tv-z]{25}$`
+	hostAndPort    = `^(` + hostnameRegex + `|` + ipv4Regex + `|\[` + ipv6Regex + `\]):` + portRegex + `$`
+	ipv4WithPrefix = `^` + ipv4Regex + ipv4PrefixLen + `$`
+	ipv6WithPrefix = `^` + ipv6Regex + ipv6PrefixLen + `$`
+	ipWithPrefix   = `^(` + ipv4Regex + ipv4PrefixLen + `|` + ipv6Regex + ipv6PrefixLen + `)$`
+
+	httpHeaderNameStrict  = "^:?[0-9a-zA-Z!#$%&'*+\\-.^_|~`]+$"
+	httpHeaderValueStrict = `^[^\x00-\x08\x0A-\x1F\x7F]*$`
+	httpHeaderNameLoose   = `^[^\x00\x0A\x0D]+$`
+	httpHeaderValueLoose  = `^[^\x00\x0A\x0D]*$`
+)
+
+// newIPv6Regex returns a regular expression for the IPv6address rule of RFC 3986.
+func newIPv6Regex() string {
+	const h16 = `[0-9a-fA-F]{1,4}`
+	ls32 := `(` + h16 + `:` + h16 + `|` + ipv4Regex + `)`
+	repeat := func(n int) string {
+		return strings.Repeat(h16+`:`, n)
+	}
+	alternatives := []string{repeat(6) + ls32, `::` + repeat(5) + ls32}
+	for i := 0; i <= 6; i++ {
+		// Up to i+1 groups before the "::", and the groups after it.
+		prefix := `(` + h16 + `)?::`
+		if i > 0 {
+			prefix = `(` + h16 + `(:` + h16 + `){0,` + strconv.Itoa(i) + `})?::`
+		}
+		switch {
+		case i <= 4:
+			alternatives = append(alternatives, prefix+repeat(4-i)+ls32)
+		case i == 5:
+			alternatives = append(alternatives, prefix+h16)
+		default:
+			alternatives = append(alternatives, prefix)
+		}
+	}
+	return `(` + strings.Join(alternatives, `|`) + `)`
+}
+
+// base64Regex returns a regular expression for n bytes encoded with base64, like protojson accepts them: with
+// the standard or the URL alphabet, with or without padding.
+func base64Regex(n int) string {
+	const char = `[A-Za-z0-9+/_-]`
+	b := strings.Builder{}
+	if groups := n / 3; groups > 0 {
+		b.WriteString(char + `{` + strconv.Itoa(groups*4) + `}`)
+	}
+	switch n % 3 {
+	case 1:
+		b.WriteString(char + `[AQgw](==)?`)
+	case 2:
+		b.WriteString(char + char + `[AEIMQUYcgkosw048]=?`)
+	}
+	return b.String()
+}
+
+// addPattern sets the pattern of the schema. When the schema already has a pattern, the pattern is added with
+// `allOf`, so both apply.
+func addPattern(schema *base.Schema, pattern string) {
+	if schema.Pattern == "" {
+		schema.Pattern = pattern
+		return
+	}
+	schema.AllOf = append(schema.AllOf, base.CreateSchemaProxy(&base.Schema{Pattern: pattern}))
+}
+
+// anyFormat requires the value to match one of the formats.
+func anyFormat(schema *base.Schema, formats ...string) {
+	anyOf := make([]*base.SchemaProxy, 0, len(formats))
+	for _, format := range formats {
+		anyOf = append(anyOf, base.CreateSchemaProxy(&base.Schema{Format: format}))
+	}
+	if len(schema.AnyOf) == 0 {
+		schema.AnyOf = anyOf
+		return
+	}
+	schema.AllOf = append(schema.AllOf, base.CreateSchemaProxy(&base.Schema{AnyOf: anyOf}))
+}
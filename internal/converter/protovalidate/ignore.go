@@ -0,0 +1,109 @@
+package protovalidate
+
+import (
+	"reflect"
+	"slices"
+
+	"buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"buf.build/go/protovalidate"
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+	"github.com/pb33f/libopenapi/utils"
+	"go.yaml.in/yaml/v4"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// annotationFields are the fields of a schema that describe a field rather than constrain its value. They stay
+// on the schema when the rules are only applied to populated values.
+var annotationFields = []string{"ParentProxy", "Title", "Description", "Deprecated", "Examples", "Extensions"}
+
+// fieldIgnore returns when protovalidate ignores the rules of the field. Like the runtime does, the fields of a
+// message oneof rule ignore their rules when they're unpopulated, unless they set `ignore` themselves.
+func fieldIgnore(rules *validate.FieldRules, desc protoreflect.FieldDescriptor) validate.Ignore {
+	if rules.HasIgnore() || desc == nil || desc.ContainingMessage() == nil {
+		return rules.GetIgnore()
+	}
+	msgRules, err := protovalidate.ResolveMessageRules(desc.ContainingMessage())
+	if err != nil {
+		return rules.GetIgnore()
+	}
+	for _, oneofRule := range msgRules.GetOneof() {
+		if slices.Contains(oneofRule.GetFields(), string(desc.Name())) {
+			return validate.Ignore_IGNORE_IF_ZERO_VALUE
+		}
+	}
+	return rules.GetIgnore()
+}
+
+// ignoreZeroValue moves the keywords that were set on the schema since before into an `anyOf` that also allows
+// the zero value, because protovalidate skips the rules of a field without presence when it has its zero value.
+// The keywords are merged into an existing `anyOf` for the same zero value, so annotating a schema again doesn't
+// repeat them.
+func ignoreZeroValue(schema *base.Schema, before *base.Schema, zero *base.Schema) {
+	if zero == nil {
+		return
+	}
+	constraints := &base.Schema{}
+	current, previous, moved := reflect.ValueOf(schema).Elem(), reflect.ValueOf(before).Elem(), reflect.ValueOf(constraints).Elem()
+	changed := false
+	for i := 0; i < current.NumField(); i++ {
+		field := current.Type().Field(i)
+		if !field.IsExported() || slices.Contains(annotationFields, field.Name) {
+			continue
+		}
+		if reflect.DeepEqual(current.Field(i).Interface(), previous.Field(i).Interface()) {
+			continue
+		}
+		moved.Field(i).Set(current.Field(i))
+		current.Field(i).Set(previous.Field(i))
+		changed = true
+	}
+	if !changed {
+		return
+	}
+	if len(schema.AnyOf) == 2 && reflect.DeepEqual(schema.AnyOf[0].Schema(), zero) {
+		existing := reflect.ValueOf(schema.AnyOf[1].Schema()).Elem()
+		for i := 0; i < moved.NumField(); i++ {
+			if moved.Type().Field(i).IsExported() && !moved.Field(i).IsZero() {
+				existing.Field(i).Set(moved.Field(i))
+			}
+		}
+		return
+	}
+	anyOf := []*base.SchemaProxy{base.CreateSchemaProxy(zero), base.CreateSchemaProxy(constraints)}
+	if len(schema.AnyOf) == 0 {
+		schema.AnyOf = anyOf
+		return
+	}
+	schema.AllOf = append(schema.AllOf, base.CreateSchemaProxy(&base.Schema{AnyOf: anyOf}))
+}
+
+// zeroValueSchema returns a schema for the zero value of the field, or of its elements when item is true. nil is
+// returned for messages, since their zero value isn't distinguishable in JSON.
+func zeroValueSchema(desc protoreflect.FieldDescriptor, item bool) *base.Schema {
+	switch {
+	case !item && desc.IsList():
+		return &base.Schema{Const: utils.CreateEmptySequenceNode()}
+	case !item && desc.IsMap():
+		return &base.Schema{Const: utils.CreateEmptyMapNode()}
+	}
+	switch desc.Kind() {
+	case protoreflect.BoolKind:
+		return &base.Schema{Const: utils.CreateBoolNode("false")}
+	case protoreflect.StringKind, protoreflect.BytesKind:
+		return &base.Schema{Const: utils.CreateStringNode("")}
+	case protoreflect.EnumKind:
+		zero := desc.Enum().Values().ByNumber(0)
+		if zero == nil {
+			return nil
+		}
+		return &base.Schema{Enum: []*yaml.Node{utils.CreateStringNode(string(zero.Name())), utils.CreateIntNode("0")}}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		// 64-bit integers may also be strings.
+		return &base.Schema{Enum: []*yaml.Node{utils.CreateIntNode("0"), utils.CreateStringNode("0")}}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return nil
+	default:
+		return &base.Schema{Const: utils.CreateIntNode("0")}
+	}
+}
@@ -2,6 +2,7 @@ package protovalidate
 
 import (
 	"log/slog"
+	"regexp"
 	"slices"
 	"strconv"
 	"strings"
@@ -10,6 +11,7 @@ import (
 	"buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
 	"buf.build/go/protovalidate"
 	"github.com/pb33f/libopenapi/datamodel/high/base"
+	"github.com/pb33f/libopenapi/orderedmap"
 	"github.com/pb33f/libopenapi/utils"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/options"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/util"
@@ -31,27 +33,33 @@ func SchemaWithMessageAnnotations(opts options.Options, schema *base.Schema, des
 	if rules == nil {
 		return schema
 	}
-	updateWithCEL(schema, rules.GetCel(), nil, nil)
-	if len(rules.Oneof) > 0 {
-		for _, oneofRule := range rules.GetOneof() {
-			if oneofRule.GetRequired() {
-				var oneOfs []*base.SchemaProxy
-				for _, fieldName := range oneofRule.GetFields() {
-					fieldDesc := desc.Fields().ByName(protoreflect.Name(fieldName))
-					if fieldDesc == nil {
-						opts.Logger.Warn("oneof rule references unknown field", "field", fieldName, "message", desc.FullName())
-						continue
-					}
-					oneOfs = append(oneOfs, base.CreateSchemaProxy(&base.Schema{
-						Required: []string{util.MakeFieldName(opts, fieldDesc)},
-					}))
-				}
-				if len(oneOfs) > 0 {
-					schema.AllOf = append(schema.AllOf, base.CreateSchemaProxy(&base.Schema{
-						OneOf: oneOfs,
-					}))
-				}
+	addRulesExtension(opts, schema, rules)
+	updateWithCELRules(opts, schema, rules.GetCel(), celTranslator{message: desc})
+	for _, oneofRule := range rules.GetOneof() {
+		var oneOfs, anyOfs []*base.SchemaProxy
+		for _, fieldName := range oneofRule.GetFields() {
+			fieldDesc := desc.Fields().ByName(protoreflect.Name(fieldName))
+			if fieldDesc == nil {
+				opts.Logger.Warn("oneof rule references unknown field", "field", fieldName, "message", desc.FullName())
+				continue
 			}
+			name := util.MakeFieldName(opts, fieldDesc)
+			oneOfs = append(oneOfs, base.CreateSchemaProxy(&base.Schema{Required: []string{name}}))
+			anyOfs = append(anyOfs, base.CreateSchemaProxy(&base.Schema{Required: []string{name}}))
+		}
+		if !oneofRule.GetRequired() {
+			// At most one of the fields may be set, so setting none of them is valid too.
+			if len(oneOfs) < 2 {
+				continue
+			}
+			oneOfs = append(oneOfs, base.CreateSchemaProxy(&base.Schema{
+				Not: base.CreateSchemaProxy(&base.Schema{AnyOf: anyOfs}),
+			}))
+		}
+		if len(oneOfs) > 0 {
+			schema.AllOf = append(schema.AllOf, base.CreateSchemaProxy(&base.Schema{
+				OneOf: oneOfs,
+			}))
 		}
 	}
 	return schema
@@ -66,6 +74,19 @@ func SchemaWithFieldAnnotations(opts options.Options, schema *base.Schema, desc
 	if rules == nil {
 		return schema
 	}
+	if !onlyScalar {
+		addRulesExtension(opts, schema, rules)
+	}
+	return SchemaWithFieldRules(opts, schema, desc, rules, onlyScalar)
+}
+
+// SchemaWithFieldRules applies the rules of a field to its schema. Rules of other validation libraries are
+// converted to protovalidate rules and applied with it too, so they produce the same schemas.
+func SchemaWithFieldRules(opts options.Options, schema *base.Schema, desc protoreflect.FieldDescriptor, rules *validate.FieldRules, onlyScalar bool) *base.Schema {
+	ignore := fieldIgnore(rules, desc)
+	if ignore == validate.Ignore_IGNORE_ALWAYS {
+		return schema
+	}
 
 	if rules.Required != nil && *rules.Required {
 		parent := schema.ParentProxy.Schema()
@@ -74,9 +95,20 @@ func SchemaWithFieldAnnotations(opts options.Options, schema *base.Schema, desc
 		}
 	}
 
+	// A field with presence is unpopulated when it's absent, which JSON schema already allows. A field without
+	// presence is also unpopulated when it has its zero value.
+	ignoreZero := !onlyScalar && ignore == validate.Ignore_IGNORE_IF_ZERO_VALUE && !desc.HasPresence()
+	before := *schema
+	if ignoreZero {
+		defer ignoreZeroValue(schema, &before, zeroValueSchema(desc, false))
+	}
+
 	rulesClone := proto.Clone(rules).(*validate.FieldRules)
 	updateSchemaWithFieldRules(opts, schema, rulesClone, onlyScalar, desc)
-	updateWithCEL(schema, rulesClone.GetCel(), nil, nil)
+	// The CEL rules of a repeated field apply to the list, not to its items.
+	if !onlyScalar {
+		updateWithCELRules(opts, schema, rulesClone.GetCel(), celTranslator{field: desc})
+	}
 	return schema
 }
 
@@ -89,7 +121,13 @@ func PopulateParentProperties(opts options.Options, parent *base.Schema, desc pr
 		opts.Logger.Warn("unable to resolve field rules", slog.Any("error", err))
 		return parent
 	}
-	if rules == nil {
+	return ParentPropertiesWithFieldRules(opts, parent, desc, rules)
+}
+
+// ParentPropertiesWithFieldRules marks the field as required in the schema of its message when the rules
+// require it.
+func ParentPropertiesWithFieldRules(opts options.Options, parent *base.Schema, desc protoreflect.FieldDescriptor, rules *validate.FieldRules) *base.Schema {
+	if parent == nil || rules == nil || fieldIgnore(rules, desc) == validate.Ignore_IGNORE_ALWAYS {
 		return parent
 	}
 	if rules.Required != nil && *rules.Required {
@@ -196,7 +234,7 @@ func updateSchemaWithFieldRules(opts options.Options, schema *base.Schema, rules
 			if predefinedRules == nil {
 				continue
 			}
-			updateWithCEL(schema, predefinedRules.GetCel(), &entry.v, entry.fd)
+			updateWithCEL(opts, schema, predefinedRules.GetCel(), &entry.v, entry.fd)
 		}
 	}
 
@@ -210,10 +248,39 @@ func updateSchemaWithFieldRules(opts options.Options, schema *base.Schema, rules
 	}
 }
 
-func updateWithCEL(schema *base.Schema, rules []*validate.Rule, val *protoreflect.Value, fieldDesc protoreflect.FieldDescriptor) {
+// updateWithCELRules applies the CEL rules that can be translated to JSON schema keywords and describes the
+// others. All rules are listed in the `x-cel-rules` extension, unless the `x-protovalidate` extension already
+// lists them.
+func updateWithCELRules(opts options.Options, schema *base.Schema, rules []*validate.Rule, translator celTranslator) {
 	if len(rules) == 0 {
 		return
 	}
+	slices.SortFunc(rules, func(a, b *validate.Rule) int {
+		return strings.Compare(a.GetId(), b.GetId())
+	})
+	var untranslated []*validate.Rule
+	for _, rule := range rules {
+		constraints, ok := translator.translate(rule.GetExpression())
+		if !ok {
+			untranslated = append(untranslated, rule)
+			continue
+		}
+		applyCELConstraints(opts, schema, constraints)
+	}
+	updateWithCEL(opts, schema, untranslated, nil, nil)
+	if opts.WithProtovalidateExtension {
+		return
+	}
+	if schema.Extensions == nil {
+		schema.Extensions = orderedmap.New[string, *yaml.Node]()
+	}
+	schema.Extensions.Set("x-cel-rules", celRulesExtension(rules))
+}
+
+func updateWithCEL(opts options.Options, schema *base.Schema, rules []*validate.Rule, val *protoreflect.Value, fieldDesc protoreflect.FieldDescriptor) {
+	if len(rules) == 0 || opts.WithProtovalidateExtension {
+		return
+	}
 	b := strings.Builder{}
 	if schema.Description != "" {
 		b.WriteString(strings.TrimSpace(schema.Description))
@@ -854,6 +921,10 @@ func updateSchemaString(opts options.Options, schema *base.Schema, constraint *v
 		constraint.MinLen = nil
 		constraint.MaxLen = nil
 		constraint.Pattern = nil
+		constraint.Prefix = nil
+		constraint.Suffix = nil
+		constraint.Contains = nil
+		constraint.NotContains = nil
 		constraint.In = nil
 		constraint.NotIn = nil
 		constraint.Example = nil
@@ -892,77 +963,112 @@ func updateSchemaString(opts options.Options, schema *base.Schema, constraint *v
 		}
 		schema.Not = base.CreateSchemaProxy(&base.Schema{Type: schema.Type, Enum: items})
 	}
-	switch v := constraint.WellKnown.(type) {
-	case *validate.StringRules_Email:
-		if v.Email {
-			schema.Format = "email"
-			constraint.ClearWellKnown()
-		}
-	case *validate.StringRules_Hostname:
-		if v.Hostname {
-			schema.Format = "hostname"
-			constraint.ClearWellKnown()
-		}
-	case *validate.StringRules_Ip:
-		if v.Ip {
-			schema.Format = "ip"
-			constraint.ClearWellKnown()
-		}
-	case *validate.StringRules_Ipv4:
-		if v.Ipv4 {
-			schema.Format = "ipv4"
-			constraint.ClearWellKnown()
-		}
-	case *validate.StringRules_Ipv6:
-		if v.Ipv6 {
-			schema.Format = "ipv6"
-			constraint.ClearWellKnown()
-		}
-	case *validate.StringRules_Uri:
-		if v.Uri {
-			schema.Format = "uri"
-			constraint.ClearWellKnown()
-		}
-	case *validate.StringRules_UriRef:
-		if v.UriRef {
-			schema.Format = "uri-ref"
-			constraint.ClearWellKnown()
-		}
-	case *validate.StringRules_Address:
-		if v.Address {
-			schema.Format = "address"
-			constraint.ClearWellKnown()
-		}
-	case *validate.StringRules_Uuid:
-		if v.Uuid {
-			schema.Format = "uuid"
-			constraint.ClearWellKnown()
-		}
-	case *validate.StringRules_IpWithPrefixlen:
-	case *validate.StringRules_Ipv4WithPrefixlen:
-	case *validate.StringRules_Ipv6WithPrefixlen:
-	case *validate.StringRules_IpPrefix:
-	case *validate.StringRules_Ipv4Prefix:
-	case *validate.StringRules_Ipv6Prefix:
-	case *validate.StringRules_HostAndPort:
-	case *validate.StringRules_WellKnownRegex:
-		switch v.WellKnownRegex {
-		case validate.KnownRegex_KNOWN_REGEX_HTTP_HEADER_NAME:
-		case validate.KnownRegex_KNOWN_REGEX_HTTP_HEADER_VALUE:
+	if constraint.Prefix != nil {
+		addPattern(schema, "^"+regexp.QuoteMeta(*constraint.Prefix))
+	}
+	if constraint.Suffix != nil {
+		addPattern(schema, regexp.QuoteMeta(*constraint.Suffix)+"$")
+	}
+	if constraint.Contains != nil {
+		addPattern(schema, regexp.QuoteMeta(*constraint.Contains))
+	}
+	if constraint.NotContains != nil {
+		notContains := &base.Schema{Pattern: regexp.QuoteMeta(*constraint.NotContains)}
+		if schema.Not == nil {
+			schema.Not = base.CreateSchemaProxy(notContains)
+		} else {
+			schema.AllOf = append(schema.AllOf, base.CreateSchemaProxy(&base.Schema{Not: base.CreateSchemaProxy(notContains)}))
 		}
 	}
+	updateSchemaStringBytes(schema, constraint)
+	updateSchemaStringWellKnown(schema, constraint)
 	for _, item := range constraint.Example {
 		schema.Examples = append(schema.Examples, utils.CreateStringNode(item))
 	}
 }
 
+// updateSchemaStringBytes limits the length of the string by the byte rules. JSON schema counts characters, which
+// take one to four bytes in UTF-8, so the length is limited as far as every string within the byte limits passes.
+// The exact rules stay in the description.
+func updateSchemaStringBytes(schema *base.Schema, constraint *validate.StringRules) {
+	minBytes, maxBytes := constraint.MinBytes, constraint.MaxBytes
+	if constraint.LenBytes != nil {
+		minBytes, maxBytes = constraint.LenBytes, constraint.LenBytes
+	}
+	if minBytes != nil {
+		v := int64((*minBytes + 3) / 4)
+		if schema.MinLength == nil || *schema.MinLength < v {
+			schema.MinLength = &v
+		}
+	}
+	if maxBytes != nil {
+		v := int64(*maxBytes)
+		if schema.MaxLength == nil || *schema.MaxLength > v {
+			schema.MaxLength = &v
+		}
+	}
+}
+
+// updateSchemaStringWellKnown maps the well-known string rules to a JSON schema format, or to a pattern when
+// JSON schema has no format for the rule.
+func updateSchemaStringWellKnown(schema *base.Schema, constraint *validate.StringRules) {
+	switch {
+	case constraint.GetEmail():
+		schema.Format = "email"
+	case constraint.GetHostname():
+		schema.Format = "hostname"
+	case constraint.GetIp():
+		anyFormat(schema, "ipv4", "ipv6")
+	case constraint.GetIpv4():
+		schema.Format = "ipv4"
+	case constraint.GetIpv6():
+		schema.Format = "ipv6"
+	case constraint.GetUri():
+		schema.Format = "uri"
+	case constraint.GetUriRef():
+		schema.Format = "uri-reference"
+	case constraint.GetAddress():
+		anyFormat(schema, "hostname", "ipv4", "ipv6")
+	case constraint.GetUuid():
+		schema.Format = "uuid"
+	case constraint.GetTuuid():
+		addPattern(schema, tuuidRegex)
+	case constraint.GetIpWithPrefixlen(), constraint.GetIpPrefix():
+		addPattern(schema, ipWithPrefix)
+	case constraint.GetIpv4WithPrefixlen(), constraint.GetIpv4Prefix():
+		addPattern(schema, ipv4WithPrefix)
+	case constraint.GetIpv6WithPrefixlen(), constraint.GetIpv6Prefix():
+		addPattern(schema, ipv6WithPrefix)
+	case constraint.GetHostAndPort():
+		addPattern(schema, hostAndPort)
+	case constraint.GetUlid():
+		addPattern(schema, ulidRegex)
+	case constraint.GetWellKnownRegex() == validate.KnownRegex_KNOWN_REGEX_HTTP_HEADER_NAME:
+		if constraint.Strict == nil || *constraint.Strict {
+			addPattern(schema, httpHeaderNameStrict)
+		} else {
+			addPattern(schema, httpHeaderNameLoose)
+		}
+		constraint.Strict = nil
+	case constraint.GetWellKnownRegex() == validate.KnownRegex_KNOWN_REGEX_HTTP_HEADER_VALUE:
+		if constraint.Strict == nil || *constraint.Strict {
+			addPattern(schema, httpHeaderValueStrict)
+		} else {
+			addPattern(schema, httpHeaderValueLoose)
+		}
+		constraint.Strict = nil
+	default:
+		return
+	}
+	constraint.ClearWellKnown()
+}
+
 func updateSchemaBytes(opts options.Options, schema *base.Schema, constraint *validate.BytesRules) {
 	defer func() {
 		constraint.Const = nil
 		constraint.Len = nil
 		constraint.MinLen = nil
 		constraint.MaxLen = nil
-		constraint.Pattern = nil
 		constraint.In = nil
 		constraint.NotIn = nil
 		constraint.Example = nil
@@ -971,22 +1077,20 @@ func updateSchemaBytes(opts options.Options, schema *base.Schema, constraint *va
 	if constraint.Const != nil {
 		schema.Const = utils.CreateStringNode(string(constraint.Const))
 	}
+	// Bytes are base64-encoded in JSON, with or without padding, so the length rules limit the number of
+	// encoded characters. The pattern, prefix, suffix and contains rules apply to the decoded bytes, which JSON
+	// schema can't check, so they stay in the description.
 	if constraint.Len != nil {
-		v := int64(*constraint.Len)
-		schema.MaxLength = &v
-		schema.MinLength = &v
+		addPattern(schema, "^"+base64Regex(int(*constraint.Len))+"$")
 	}
 	if constraint.MinLen != nil {
-		v := int64(*constraint.MinLen)
+		v := int64((4**constraint.MinLen + 2) / 3)
 		schema.MinLength = &v
 	}
 	if constraint.MaxLen != nil {
-		v := int64(*constraint.MaxLen)
+		v := int64((*constraint.MaxLen + 2) / 3 * 4)
 		schema.MaxLength = &v
 	}
-	if constraint.Pattern != nil {
-		schema.Pattern = *constraint.Pattern
-	}
 	if len(constraint.In) > 0 {
 		items := make([]*yaml.Node, len(constraint.In))
 		for i, item := range constraint.In {
@@ -1001,22 +1105,19 @@ func updateSchemaBytes(opts options.Options, schema *base.Schema, constraint *va
 		}
 		schema.Not = base.CreateSchemaProxy(&base.Schema{Type: schema.Type, Enum: items})
 	}
-	switch v := constraint.WellKnown.(type) {
-	case *validate.BytesRules_Ip:
-		if v.Ip {
-			schema.Format = "ip"
-			constraint.ClearWellKnown()
-		}
-	case *validate.BytesRules_Ipv4:
-		if v.Ipv4 {
-			schema.Format = "ipv4"
-			constraint.ClearWellKnown()
-		}
-	case *validate.BytesRules_Ipv6:
-		if v.Ipv6 {
-			schema.Format = "ipv6"
-			constraint.ClearWellKnown()
-		}
+	// Bytes are base64-encoded in JSON, so the well-known rules limit the number of encoded bytes.
+	var wellKnown string
+	switch {
+	case constraint.GetIp():
+		wellKnown = "^(" + base64Regex(4) + "|" + base64Regex(16) + ")$"
+	case constraint.GetIpv4():
+		wellKnown = "^" + base64Regex(4) + "$"
+	case constraint.GetIpv6(), constraint.GetUuid():
+		wellKnown = "^" + base64Regex(16) + "$"
+	}
+	if wellKnown != "" {
+		addPattern(schema, wellKnown)
+		constraint.ClearWellKnown()
 	}
 	for _, item := range constraint.Example {
 		schema.Examples = append(schema.Examples, utils.CreateStringNode(string(item)))
@@ -1098,8 +1199,8 @@ func updateSchemaRepeated(opts options.Options, schema *base.Schema, constraint
 		v := int64(*constraint.MinItems)
 		schema.MinItems = &v
 	}
-	if constraint.Items != nil && schema.Items != nil && schema.Items.A != nil && !schema.Items.A.IsReference() {
-		updateSchemaWithFieldRules(opts, schema.Items.A.Schema(), constraint.Items, true, desc)
+	if constraint.Items != nil && schema.Items != nil && schema.Items.A != nil {
+		schema.Items.A = updateElementSchema(opts, schema.Items.A, constraint.Items, celTranslator{field: desc, item: true})
 	}
 }
 
@@ -1117,15 +1218,39 @@ func updateSchemaMap(opts options.Options, schema *base.Schema, constraint *vali
 		v := int64(*constraint.MaxPairs)
 		schema.MaxProperties = &v
 	}
-	// NOTE: Most of these properties don't make sense for object keys
-	// updateSchemaWithFieldRules(schema, constraint.Keys)
-	if schema.AdditionalProperties != nil && constraint.Values != nil {
-		var valueDesc protoreflect.FieldDescriptor
-		if desc != nil {
-			valueDesc = desc.MapValue()
-		}
-		updateSchemaWithFieldRules(opts, schema.AdditionalProperties.A.Schema(), constraint.Values, false, valueDesc)
+	if desc == nil {
+		return
 	}
+	// Keys are always strings in JSON, so only the rules of string keys apply to the property names.
+	if constraint.Keys != nil && desc.MapKey().Kind() == protoreflect.StringKind {
+		propertyNames := base.CreateSchemaProxy(&base.Schema{Type: []string{"string"}})
+		schema.PropertyNames = updateElementSchema(opts, propertyNames, constraint.Keys, celTranslator{field: desc.MapKey()})
+	}
+	if constraint.Values != nil && schema.AdditionalProperties != nil && schema.AdditionalProperties.A != nil {
+		schema.AdditionalProperties.A = updateElementSchema(opts, schema.AdditionalProperties.A, constraint.Values, celTranslator{field: desc.MapValue()})
+	}
+}
+
+// updateElementSchema applies the rules of the items of a repeated field, or of the keys or values of a map,
+// to the schema of the element. Elements that reference the schema of a message or enum are wrapped in an
+// `allOf`, so the rules aren't dropped.
+func updateElementSchema(opts options.Options, proxy *base.SchemaProxy, rules *validate.FieldRules, translator celTranslator) *base.SchemaProxy {
+	if proto.Size(rules) == 0 || rules.GetIgnore() == validate.Ignore_IGNORE_ALWAYS {
+		return proxy
+	}
+	schema := proxy.Schema()
+	if proxy.IsReference() {
+		schema = &base.Schema{AllOf: []*base.SchemaProxy{proxy}}
+		proxy = base.CreateSchemaProxy(schema)
+	}
+	before := *schema
+	// The CEL rules are applied last, so their keywords are merged with the ones of the field rules.
+	updateSchemaWithFieldRules(opts, schema, rules, true, translator.field)
+	updateWithCELRules(opts, schema, rules.GetCel(), translator)
+	if rules.GetIgnore() == validate.Ignore_IGNORE_IF_ZERO_VALUE {
+		ignoreZeroValue(schema, &before, zeroValueSchema(translator.field, true))
+	}
+	return proxy
 }
 
 func updateSchemaAny(opts options.Options, schema *base.Schema, constraint *validate.AnyRules) {
@@ -0,0 +1,178 @@
+package converter
+
+import (
+	"log/slog"
+	"strings"
+
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+	"github.com/pb33f/libopenapi/orderedmap"
+	"go.yaml.in/yaml/v4"
+
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/options"
+)
+
+const componentsRefPrefix = "#/components/"
+
+// pruneComponents walks every reference that can be reached from the paths, webhooks and security of the
+// document and reports the ones that point to components that don't exist. With trim-unused-types, the
+// components that can't be reached are removed, including the ones that come from base, override and config
+// documents or that are left over after visibility filtering. Components that are only mentioned by name would be
+// removed too, so the components that the generator adds, like the Connect stream and error schemas, are always
+// referenced with `$ref`.
+func pruneComponents(opts options.Options, spec *v3.Document) {
+	if spec.Components == nil {
+		return
+	}
+	components := spec.Components
+	lookups := map[string]func(name string) (any, bool){
+		"schemas":         lookupComponent(components.Schemas),
+		"responses":       lookupComponent(components.Responses),
+		"parameters":      lookupComponent(components.Parameters),
+		"examples":        lookupComponent(components.Examples),
+		"requestBodies":   lookupComponent(components.RequestBodies),
+		"headers":         lookupComponent(components.Headers),
+		"securitySchemes": lookupComponent(components.SecuritySchemes),
+		"links":           lookupComponent(components.Links),
+		"callbacks":       lookupComponent(components.Callbacks),
+		"pathItems":       lookupComponent(components.PathItems),
+		"mediaTypes":      lookupComponent(components.MediaTypes),
+	}
+
+	reachable := map[string]struct{}{}
+	queue := []any{spec.Paths, spec.Webhooks, spec.Security}
+	// Security schemes are referenced by name instead of $ref, so they're all kept and walked.
+	if components.SecuritySchemes != nil {
+		for scheme := range components.SecuritySchemes.ValuesFromOldest() {
+			queue = append(queue, scheme)
+		}
+	}
+	for len(queue) > 0 {
+		value := queue[0]
+		queue = queue[1:]
+		node := &yaml.Node{}
+		if err := node.Encode(value); err != nil {
+			opts.Logger.Warn("unable to encode value to find references", slog.Any("error", err))
+			continue
+		}
+		for _, ref := range collectRefs(node, nil) {
+			kind, name, ok := parseComponentRef(ref)
+			if !ok {
+				continue
+			}
+			key := componentsRefPrefix + kind + "/" + name
+			if _, ok := reachable[key]; ok {
+				continue
+			}
+			reachable[key] = struct{}{}
+			lookup, ok := lookups[kind]
+			if !ok {
+				opts.Logger.Warn("dangling reference", slog.String("ref", ref))
+				continue
+			}
+			component, ok := lookup(name)
+			if !ok {
+				opts.Logger.Warn("dangling reference", slog.String("ref", ref))
+				continue
+			}
+			queue = append(queue, component)
+		}
+	}
+
+	if !opts.TrimUnusedTypes {
+		return
+	}
+	components.Schemas = removeUnreachable(opts, "schemas", components.Schemas, reachable)
+	components.Responses = removeUnreachable(opts, "responses", components.Responses, reachable)
+	components.Parameters = removeUnreachable(opts, "parameters", components.Parameters, reachable)
+	components.Examples = removeUnreachable(opts, "examples", components.Examples, reachable)
+	components.RequestBodies = removeUnreachable(opts, "requestBodies", components.RequestBodies, reachable)
+	components.Headers = removeUnreachable(opts, "headers", components.Headers, reachable)
+	components.Links = removeUnreachable(opts, "links", components.Links, reachable)
+	components.Callbacks = removeUnreachable(opts, "callbacks", components.Callbacks, reachable)
+	components.PathItems = removeUnreachable(opts, "pathItems", components.PathItems, reachable)
+	components.MediaTypes = removeUnreachable(opts, "mediaTypes", components.MediaTypes, reachable)
+}
+
+func lookupComponent[T any](m *orderedmap.Map[string, T]) func(name string) (any, bool) {
+	return func(name string) (any, bool) {
+		if m == nil {
+			return nil, false
+		}
+		return m.Get(name)
+	}
+}
+
+// removeUnreachable removes the components of a kind that aren't reachable. Maps that end up empty are removed,
+// because maps that come from a base document would still render as `{}`.
+func removeUnreachable[T any](opts options.Options, kind string, m *orderedmap.Map[string, T], reachable map[string]struct{}) *orderedmap.Map[string, T] {
+	if m == nil {
+		return nil
+	}
+	for _, name := range collectKeys(m) {
+		if _, ok := reachable[componentsRefPrefix+kind+"/"+name]; !ok {
+			opts.Logger.Debug("removing unreachable component", slog.String("kind", kind), slog.String("name", name))
+			m.Delete(name)
+		}
+	}
+	if m.Len() == 0 {
+		return nil
+	}
+	return m
+}
+
+func collectKeys[T any](m *orderedmap.Map[string, T]) []string {
+	keys := make([]string, 0, m.Len())
+	for key := range m.KeysFromOldest() {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// collectRefs returns the local references of the node: the values of `$ref` and of discriminator mappings.
+func collectRefs(node *yaml.Node, refs []string) []string {
+	if node == nil {
+		return refs
+	}
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			switch {
+			case key.Value == "$ref" && value.Kind == yaml.ScalarNode:
+				if strings.HasPrefix(value.Value, componentsRefPrefix) {
+					refs = append(refs, value.Value)
+				}
+				continue
+			case key.Value == "mapping" && value.Kind == yaml.MappingNode:
+				for j := 1; j < len(value.Content); j += 2 {
+					if strings.HasPrefix(value.Content[j].Value, componentsRefPrefix) {
+						refs = append(refs, value.Content[j].Value)
+					}
+				}
+				continue
+			}
+			refs = collectRefs(value, refs)
+		}
+		return refs
+	}
+	for _, child := range node.Content {
+		refs = collectRefs(child, refs)
+	}
+	return refs
+}
+
+// parseComponentRef splits a reference like `#/components/schemas/foo.v1.Foo` into the kind and the name of the
+// component. References into a component, like `#/components/schemas/Foo/properties/bar`, point to the component
+// itself.
+func parseComponentRef(ref string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(ref, componentsRefPrefix)
+	if !ok {
+		return "", "", false
+	}
+	kind, name, ok := strings.Cut(rest, "/")
+	if !ok {
+		return "", "", false
+	}
+	name, _, _ = strings.Cut(name, "/")
+	name = strings.ReplaceAll(strings.ReplaceAll(name, "~1", "/"), "~0", "~")
+	return kind, name, true
+}
@@ -0,0 +1,122 @@
+package converter
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+	"github.com/pb33f/libopenapi/orderedmap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/options"
+	"go.yaml.in/yaml/v4"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func TestPruneComponents(t *testing.T) {
+	newSpec := func() *v3.Document {
+		schemas := orderedmap.New[string, *base.SchemaProxy]()
+		schemas.Set("Pet", base.CreateSchemaProxy(&base.Schema{
+			Type: []string{"object"},
+			Discriminator: &base.Discriminator{
+				PropertyName: "kind",
+				Mapping:      orderedmap.ToOrderedMap(map[string]string{"dog": "#/components/schemas/Dog"}),
+			},
+		}))
+		schemas.Set("Dog", base.CreateSchemaProxy(&base.Schema{Type: []string{"object"}}))
+		properties := orderedmap.New[string, *base.SchemaProxy]()
+		properties.Set("name", base.CreateSchemaProxy(&base.Schema{Type: []string{"string"}}))
+		schemas.Set("Owner", base.CreateSchemaProxy(&base.Schema{Type: []string{"object"}, Properties: properties}))
+		schemas.Set("Unused", base.CreateSchemaProxy(&base.Schema{Type: []string{"object"}}))
+
+		content := orderedmap.New[string, *v3.MediaType]()
+		content.Set("application/json", &v3.MediaType{Schema: base.CreateSchemaProxyRef("#/components/schemas/Pet")})
+		codes := orderedmap.New[string, *v3.Response]()
+		codes.Set("200", &v3.Response{Description: "Success", Content: content})
+		pathItems := orderedmap.New[string, *v3.PathItem]()
+		pathItems.Set("/pets", &v3.PathItem{Get: &v3.Operation{
+			Parameters: []*v3.Parameter{
+				{Name: "owner", In: "query", Schema: base.CreateSchemaProxyRef("#/components/schemas/Owner/properties/name")},
+				{Name: "missing", In: "query", Schema: base.CreateSchemaProxyRef("#/components/schemas/Missing")},
+			},
+			Responses: &v3.Responses{Codes: codes},
+		}})
+		return &v3.Document{
+			Paths:      &v3.Paths{PathItems: pathItems},
+			Components: &v3.Components{Schemas: schemas},
+		}
+	}
+
+	t.Run("reports dangling references", func(t *testing.T) {
+		var logs bytes.Buffer
+		opts := options.NewOptions()
+		opts.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+		spec := newSpec()
+		pruneComponents(opts, spec)
+		assert.Contains(t, logs.String(), "dangling reference")
+		assert.Contains(t, logs.String(), "#/components/schemas/Missing")
+		// Without trim-unused-types nothing is removed.
+		assert.Equal(t, 4, spec.Components.Schemas.Len())
+	})
+
+	t.Run("removes unreachable components", func(t *testing.T) {
+		opts := options.NewOptions()
+		opts.Logger = slog.New(slog.DiscardHandler)
+		opts.TrimUnusedTypes = true
+		spec := newSpec()
+		pruneComponents(opts, spec)
+		require.NotNil(t, spec.Components.Schemas)
+		var names []string
+		for name := range spec.Components.Schemas.KeysFromOldest() {
+			names = append(names, name)
+		}
+		assert.Equal(t, []string{"Pet", "Dog", "Owner"}, names)
+	})
+
+	t.Run("keeps the components of streaming methods", func(t *testing.T) {
+		schemaNames := func(parameter string) []string {
+			f, err := os.ReadFile(filepath.Join("testdata", "fileset.binpb"))
+			require.NoError(t, err)
+			fileSet := new(descriptorpb.FileDescriptorSet)
+			require.NoError(t, proto.Unmarshal(f, fileSet))
+			req := &pluginpb.CodeGeneratorRequest{
+				ProtoFile:      fileSet.GetFile(),
+				FileToGenerate: []string{"connect_streaming/connect_streaming.proto"},
+				Parameter:      proto.String(parameter),
+			}
+			b, err := proto.Marshal(req)
+			require.NoError(t, err)
+			resp, err := ConvertFrom(bytes.NewBuffer(b))
+			require.NoError(t, err)
+			require.Nil(t, resp.Error)
+			require.Len(t, resp.File, 1)
+
+			var doc struct {
+				Components struct {
+					Schemas map[string]any `yaml:"schemas"`
+				} `yaml:"components"`
+			}
+			require.NoError(t, yaml.Unmarshal([]byte(resp.File[0].GetContent()), &doc))
+			var names []string
+			for name := range doc.Components.Schemas {
+				names = append(names, name)
+			}
+			slices.Sort(names)
+			return names
+		}
+
+		names := schemaNames("with-streaming,trim-unused-types")
+		assert.Equal(t, schemaNames("with-streaming"), names)
+		assert.Contains(t, names, "connect_streaming.v1.LogLine.connect-stream")
+		assert.Contains(t, names, "connect.end-stream")
+		assert.Contains(t, names, "connect.envelope-flags")
+		assert.Contains(t, names, "connect.error")
+	})
+}
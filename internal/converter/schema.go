@@ -10,6 +10,7 @@ import (
 	"go.yaml.in/yaml/v4"
 	"google.golang.org/protobuf/reflect/protoreflect"
 
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/extensions"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/options"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/schema"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/util"
@@ -20,24 +21,32 @@ func AddMessageSchemas(opts options.Options, md protoreflect.MessageDescriptor,
 	if md == nil {
 		return
 	}
-	if visibility.ShouldBeFiltered(visibility.GetVisibilityRule(md), opts.AllowedVisibilities) {
-		opts.Logger.Debug("Filtering message due to visibility", slog.String("message", string(md.FullName())), slog.Any("restriction_selectors", opts.AllowedVisibilities))
+	if visibility.IsHidden(opts, md) {
 		return
 	}
-	if _, ok := doc.Components.Schemas.Get(string(md.FullName())); ok {
+	opts = schema.MessageOptions(opts, md)
+	if _, ok := doc.Components.Schemas.Get(schema.MessageSchemaName(opts, md)); ok {
 		return
 	}
-	name, schema := schema.MessageToSchema(opts, md)
-	if schema != nil {
-		doc.Components.Schemas.Set(name, base.CreateSchemaProxy(schema))
+	name, s := schema.MessageToSchema(opts, md)
+	if s != nil {
+		doc.Components.Schemas.Set(name, base.CreateSchemaProxy(s))
 	}
 
 	// Messages can have fields
 	fields := md.Fields()
 	for i := 0; i < fields.Len(); i++ {
+		if visibility.IsHidden(opts, fields.Get(i)) {
+			continue
+		}
 		AddFieldToSchema(opts, fields.Get(i), doc)
 	}
 
+	// Schema variants only include the types that are referenced by fields
+	if !opts.SchemaVariant.IsZero() {
+		return
+	}
+
 	// Messages can have enums
 	enums := md.Enums()
 	for i := 0; i < enums.Len(); i++ {
@@ -65,31 +74,35 @@ func AddEnumToSchema(opts options.Options, ed protoreflect.EnumDescriptor, doc *
 	if ed == nil {
 		return
 	}
-	if visibility.ShouldBeFiltered(visibility.GetVisibilityRule(ed), opts.AllowedVisibilities) {
-		opts.Logger.Debug("Filtering enum due to visibility", slog.String("enum", string(ed.FullName())), slog.Any("restriction_selectors", opts.AllowedVisibilities))
+	if visibility.IsHidden(opts, ed) {
 		return
 	}
-	if _, ok := doc.Components.Schemas.Get(string(ed.FullName())); ok {
+	if _, ok := doc.Components.Schemas.Get(schema.EnumSchemaName(opts, ed)); ok {
 		return
 	}
-	name, schema := enumToSchema(opts, ed)
-	if schema != nil {
-		doc.Components.Schemas.Set(name, base.CreateSchemaProxy(schema))
+	name, s := enumToSchema(opts, ed)
+	if s != nil {
+		doc.Components.Schemas.Set(name, base.CreateSchemaProxy(s))
 	}
 }
 
 func enumToSchema(opts options.Options, tt protoreflect.EnumDescriptor) (string, *base.Schema) {
 	opts.Logger.Debug("enumToSchema", slog.Any("descriptor", tt.FullName()))
+	// protojson accepts both the names and the numbers of enum values when unmarshalling and emits numbers
+	// instead of names with UseEnumNumbers.
+	includeNames := !opts.IsOutputSchema() || !opts.UseEnumNumbers
+	includeNumbers := opts.IncludeNumberEnumValues || opts.SchemaVariant.Input || !includeNames
 	children := []*yaml.Node{}
 	values := tt.Values()
 	for i := 0; i < values.Len(); i++ {
 		value := values.Get(i)
-		if visibility.ShouldBeFiltered(visibility.GetVisibilityRule(value), opts.AllowedVisibilities) {
-			opts.Logger.Debug("Filtering enum value due to visibility", slog.String("enum_value", string(value.FullName())), slog.Any("restriction_selectors", opts.AllowedVisibilities))
+		if visibility.IsHidden(opts, value) {
 			continue // Skip this enum value
 		}
-		children = append(children, utils.CreateStringNode(string(value.Name())))
-		if opts.IncludeNumberEnumValues {
+		if includeNames {
+			children = append(children, utils.CreateStringNode(string(value.Name())))
+		}
+		if includeNumbers {
 			children = append(children, utils.CreateIntNode(strconv.FormatInt(int64(value.Number()), 10)))
 		}
 	}
@@ -98,9 +111,16 @@ func enumToSchema(opts options.Options, tt protoreflect.EnumDescriptor) (string,
 	if opts.FullyQualifiedMessageNames {
 		title = string(tt.FullName())
 	}
-	types := []string{"string"}
-	if opts.IncludeNumberEnumValues {
-		types = append(types, "number")
+	var types []string
+	if includeNames {
+		types = append(types, "string")
+	}
+	if includeNumbers {
+		if opts.IncludeNumberEnumValues {
+			types = append(types, "number")
+		} else {
+			types = append(types, "integer")
+		}
 	}
 	s := &base.Schema{
 		Title:       title,
@@ -108,5 +128,6 @@ func enumToSchema(opts options.Options, tt protoreflect.EnumDescriptor) (string,
 		Type:        types,
 		Enum:        children,
 	}
-	return string(tt.FullName()), s
+	s = extensions.SchemaWithEnumOptions(opts, s, tt)
+	return schema.EnumSchemaName(opts, tt), s
 }
@@ -6,10 +6,12 @@ import (
 	"slices"
 	"strings"
 
+	"buf.build/go/protovalidate"
 	"github.com/pb33f/libopenapi/datamodel/high/base"
 	"github.com/pb33f/libopenapi/orderedmap"
 	"github.com/pb33f/libopenapi/utils"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/options"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/pgv"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/util"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/visibility"
 	"go.yaml.in/yaml/v4"
@@ -28,6 +30,7 @@ func MessageToSchema(opts options.Options, tt protoreflect.MessageDescriptor) (s
 		}
 		return wk.ID, wk.Schema
 	}
+	opts = MessageOptions(opts, tt)
 	title := string(tt.Name())
 	if opts.FullyQualifiedMessageNames {
 		title = string(tt.FullName())
@@ -41,12 +44,18 @@ func MessageToSchema(opts options.Options, tt protoreflect.MessageDescriptor) (s
 
 	oneOneGroups := map[protoreflect.FullName][]protoreflect.FieldDescriptor{}
 	regularProps := orderedmap.New[string, *base.SchemaProxy]()
+	// protojson accepts both the JSON name and the original proto name of a field when unmarshalling, so
+	// input schemas list the alternative name of each field as well.
+	aliases := map[string]string{}
+	var unpopulated []string
 
 	fields := tt.Fields()
 	for i := 0; i < fields.Len(); i++ {
 		field := fields.Get(i)
-		if visibility.ShouldBeFiltered(visibility.GetVisibilityRule(field), opts.AllowedVisibilities) {
-			opts.Logger.Debug("Filtering field due to visibility", slog.String("field", string(field.FullName())), slog.Any("restriction_selectors", opts.AllowedVisibilities))
+		if visibility.IsHidden(opts, field) {
+			continue
+		}
+		if IsStrippedField(opts, field) {
 			continue
 		}
 		if oneOf := field.ContainingOneof(); oneOf != nil && !oneOf.IsSynthetic() {
@@ -66,7 +75,16 @@ func MessageToSchema(opts options.Options, tt protoreflect.MessageDescriptor) (s
 				appendType(schema, "null")
 			}
 		}
-		regularProps.Set(util.MakeFieldName(opts, field), prop)
+		fieldName := util.MakeFieldName(opts, field)
+		regularProps.Set(fieldName, prop)
+		if alias := fieldNameAlias(opts, field); alias != "" {
+			aliases[fieldName] = alias
+			regularProps.Set(alias, FieldToSchema(opts, base.CreateSchemaProxy(s), field))
+		}
+		// Unset proto3 optional fields are in a synthetic oneof, which protojson doesn't emit either.
+		if field.ContainingOneof() == nil || !field.HasPresence() {
+			unpopulated = append(unpopulated, fieldName)
+		}
 	}
 
 	s.Properties = regularProps
@@ -83,13 +101,18 @@ func MessageToSchema(opts options.Options, tt protoreflect.MessageDescriptor) (s
 			slices.SortFunc(items, func(a, b protoreflect.FieldDescriptor) int {
 				return strings.Compare(string(a.Name()), string(b.Name()))
 			})
-			allOfs = append(allOfs, makeOneOfGroup(opts, items))
+			allOfs = append(allOfs, makeOneOfGroup(opts, items[0].ContainingOneof(), items))
 		}
 		if len(allOfs) == 1 {
 			s.OneOf = allOfs[0].Schema().OneOf
 		} else {
 			s.AllOf = append(s.AllOf, allOfs...)
 		}
+		if isValidated(opts, tt.ParentFile()) {
+			// additionalProperties doesn't see the properties of the oneof fields, which are declared in subschemas.
+			s.AdditionalProperties = nil
+			s.UnevaluatedProperties = &base.DynamicValue[*base.SchemaProxy, bool]{N: 1, B: false}
+		}
 	}
 
 	// if there are oneOfs and properties, we should merge them under a allOf.
@@ -105,7 +128,57 @@ func MessageToSchema(opts options.Options, tt protoreflect.MessageDescriptor) (s
 
 	// Apply Updates from Options
 	s = opts.MessageAnnotator.AnnotateMessage(opts, s, tt)
-	return string(tt.FullName()), s
+
+	// protojson.MarshalOptions.EmitUnpopulated emits every field that isn't part of a oneof.
+	if opts.IsOutputSchema() && opts.EmitUnpopulated {
+		for _, name := range unpopulated {
+			s.Required = util.AppendStringDedupe(s.Required, name)
+		}
+	}
+	requireEitherAlias(s, aliases)
+	return MessageSchemaName(opts, tt), s
+}
+
+// fieldNameAlias returns the alternative name protojson accepts for the field in input schemas. An empty
+// string is returned if there is no alternative name.
+func fieldNameAlias(opts options.Options, field protoreflect.FieldDescriptor) string {
+	if !opts.SchemaVariant.Input {
+		return ""
+	}
+	alias := string(field.Name())
+	if opts.WithProtoNames {
+		alias = field.JSONName()
+	}
+	if alias == util.MakeFieldName(opts, field) {
+		return ""
+	}
+	return alias
+}
+
+// requireEitherAlias replaces required field names that have an alias with a requirement that either the
+// field name or its alias is present.
+func requireEitherAlias(s *base.Schema, aliases map[string]string) {
+	if len(aliases) == 0 || len(s.Required) == 0 {
+		return
+	}
+	required := make([]string, 0, len(s.Required))
+	for _, name := range s.Required {
+		alias, ok := aliases[name]
+		if !ok {
+			required = append(required, name)
+			continue
+		}
+		s.AllOf = append(s.AllOf, base.CreateSchemaProxy(&base.Schema{
+			AnyOf: []*base.SchemaProxy{
+				base.CreateSchemaProxy(&base.Schema{Required: []string{name}}),
+				base.CreateSchemaProxy(&base.Schema{Required: []string{alias}}),
+			},
+		}))
+	}
+	if len(required) == 0 {
+		required = nil
+	}
+	s.Required = required
 }
 
 func FieldToSchema(opts options.Options, parent *base.SchemaProxy, tt protoreflect.FieldDescriptor) *base.SchemaProxy {
@@ -148,13 +221,22 @@ func FieldToSchema(opts options.Options, parent *base.SchemaProxy, tt protorefle
 		case protoreflect.MessageKind, protoreflect.EnumKind:
 			msg := ScalarFieldToSchema(opts, parent, tt, false)
 			ref := ReferenceFieldToSchema(opts, parent, tt)
-			if tt.HasOptionalKeyword() {
+			// protojson.MarshalOptions.EmitUnpopulated emits null for unset message fields.
+			isNullable := tt.Kind() == protoreflect.MessageKind && opts.IsOutputSchema() && opts.EmitUnpopulated
+			if tt.HasOptionalKeyword() || isNullable {
 				msg.OneOf = []*base.SchemaProxy{
 					ref,
 					base.CreateSchemaProxy(&base.Schema{Type: []string{"null"}}),
 				}
 			} else {
 				extensions := orderedmap.New[string, *yaml.Node]()
+				// The extensions of the field, like its rules and mapped options, describe the field and not the
+				// referenced schema, so they stay next to the $ref.
+				for name, value := range msg.Extensions.FromOldest() {
+					if strings.HasPrefix(name, "x-") {
+						extensions.Set(name, value)
+					}
+				}
 				extensions.Set("$ref", utils.CreateStringNode(ref.GetReference()))
 				msg.Extensions = extensions
 			}
@@ -189,9 +271,16 @@ func ScalarFieldToSchema(opts options.Options, parent *base.SchemaProxy, tt prot
 		//       cannot fit into a JSON number type
 		s.Type = []string{"integer", "string"}
 		s.Format = "int64"
+		if opts.IsOutputSchema() {
+			// protojson always emits 64-bit integers as strings
+			s.Type = []string{"string"}
+		}
 	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind: // uint64 types
 		s.Type = []string{"integer", "string"}
 		s.Format = "int64"
+		if opts.IsOutputSchema() {
+			s.Type = []string{"string"}
+		}
 	case protoreflect.DoubleKind:
 		s.Type = []string{"number"}
 		s.Format = "double"
@@ -213,17 +302,21 @@ func ReferenceFieldToSchema(opts options.Options, parent *base.SchemaProxy, tt p
 	switch tt.Kind() {
 	case protoreflect.MessageKind:
 		opts.FieldReferenceAnnotator.AnnotateFieldReference(opts, parent.Schema(), tt)
-		return base.CreateSchemaProxyRef("#/components/schemas/" + string(tt.Message().FullName()))
+		return base.CreateSchemaProxyRef("#/components/schemas/" + MessageSchemaName(opts, tt.Message()))
 	case protoreflect.EnumKind:
 		opts.FieldReferenceAnnotator.AnnotateFieldReference(opts, parent.Schema(), tt)
-		return base.CreateSchemaProxyRef("#/components/schemas/" + string(tt.Enum().FullName()))
+		return base.CreateSchemaProxyRef("#/components/schemas/" + EnumSchemaName(opts, tt.Enum()))
 	default:
 		panic(fmt.Errorf("ReferenceFieldToSchema called with unknown kind: %T", tt.Kind()))
 	}
 }
 
-func makeOneOfGroup(opts options.Options, fields []protoreflect.FieldDescriptor) *base.SchemaProxy {
-	rootSchemas := make([]*base.SchemaProxy, 0, len(fields))
+// makeOneOfGroup returns the schema of a oneof, with one option for each of its fields. In files that are validated
+// by protovalidate or protoc-gen-validate, at most one of the fields may be set, so there's also an option for none
+// of them, unless the oneof is required.
+func makeOneOfGroup(opts options.Options, oneof protoreflect.OneofDescriptor, fields []protoreflect.FieldDescriptor) *base.SchemaProxy {
+	rootSchemas := make([]*base.SchemaProxy, 0, len(fields)+1)
+	var anyField []*base.SchemaProxy
 	for _, field := range fields {
 		schema := &base.Schema{
 			/*
@@ -240,13 +333,60 @@ func makeOneOfGroup(opts options.Options, fields []protoreflect.FieldDescriptor)
 		propSchema := FieldToSchema(opts, base.CreateSchemaProxy(schema), field)
 		schema.Properties.Set(fieldName, propSchema)
 		schema.Required = []string{fieldName}
+		anyField = append(anyField, base.CreateSchemaProxy(&base.Schema{Required: []string{fieldName}}))
+		if alias := fieldNameAlias(opts, field); alias != "" {
+			schema.Properties.Set(alias, FieldToSchema(opts, base.CreateSchemaProxy(schema), field))
+			requireEitherAlias(schema, map[string]string{fieldName: alias})
+			anyField = append(anyField, base.CreateSchemaProxy(&base.Schema{Required: []string{alias}}))
+		}
 
 		rootSchemas = append(rootSchemas, base.CreateSchemaProxy(schema))
 	}
 
+	if isValidated(opts, oneof.ParentFile()) && !isOneofRequired(opts, oneof) {
+		rootSchemas = append(rootSchemas, base.CreateSchemaProxy(&base.Schema{
+			Not: base.CreateSchemaProxy(&base.Schema{AnyOf: anyField}),
+		}))
+	}
 	return base.CreateSchemaProxy(&base.Schema{OneOf: rootSchemas})
 }
 
+// isValidated reports whether the file imports the rules of an enabled validation feature, so its oneofs are
+// rendered like the validator treats them.
+func isValidated(opts options.Options, file protoreflect.FileDescriptor) bool {
+	imports := file.Imports()
+	for i := 0; i < imports.Len(); i++ {
+		switch imports.Get(i).Path() {
+		case "buf/validate/validate.proto":
+			if opts.FeatureEnabled(options.FeatureProtovalidate) {
+				return true
+			}
+		case "validate/validate.proto":
+			if opts.FeatureEnabled(options.FeaturePGV) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isOneofRequired reports whether `(buf.validate.oneof).required`, or `(validate.required)` with the pgv feature,
+// requires one of the fields of the oneof to be set.
+func isOneofRequired(opts options.Options, oneof protoreflect.OneofDescriptor) bool {
+	if opts.FeatureEnabled(options.FeaturePGV) && pgv.IsOneofRequired(oneof) {
+		return true
+	}
+	if !opts.FeatureEnabled(options.FeatureProtovalidate) {
+		return false
+	}
+	rules, err := protovalidate.ResolveOneofRules(oneof)
+	if err != nil {
+		opts.Logger.Warn("unable to resolve oneof rules", slog.Any("error", err))
+		return false
+	}
+	return rules.GetRequired()
+}
+
 func appendType(s *base.Schema, newType string) {
 	if s.Type == nil {
 		s.Type = []string{newType}
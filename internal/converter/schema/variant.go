@@ -0,0 +1,150 @@
+package schema
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/options"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/util"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// MessageSchemaName returns the component name of the schema for the given message, taking the current schema
+// variant into account. Well-known types are the same for every variant so they never get a suffix.
+func MessageSchemaName(opts options.Options, md protoreflect.MessageDescriptor) string {
+	name := string(md.FullName())
+	if util.IsWellKnown(md) {
+		return name
+	}
+	return name + messageVariant(opts, md).Suffix()
+}
+
+// EnumSchemaName returns the component name of the schema for the given enum. Enums only differ between input
+// and output schemas, so the `.input` suffix is the only one they get.
+func EnumSchemaName(opts options.Options, ed protoreflect.EnumDescriptor) string {
+	return string(ed.FullName()) + options.SchemaVariant{Input: opts.SchemaVariant.Input}.Suffix()
+}
+
+// MessageSchemaRef returns a reference to the schema of the given message for the current schema variant.
+func MessageSchemaRef(opts options.Options, md protoreflect.MessageDescriptor) string {
+	return "#/components/schemas/" + util.FormatTypeRef(MessageSchemaName(opts, md))
+}
+
+// MessageRefByName returns the schema reference of the message with the given full name. Messages that aren't
+// part of the generation request are referenced by their full name.
+func MessageRefByName(opts options.Options, name string) string {
+	if opts.Files != nil {
+		if desc, err := opts.Files.FindDescriptorByName(protoreflect.FullName(name)); err == nil {
+			if md, ok := desc.(protoreflect.MessageDescriptor); ok {
+				return MessageSchemaRef(opts, md)
+			}
+		}
+	}
+	return "#/components/schemas/" + util.FormatTypeRef(name)
+}
+
+// RequestOptions returns the options that should be used to generate schemas that describe the request of
+// the given method.
+func RequestOptions(opts options.Options, md protoreflect.MethodDescriptor) options.Options {
+	variant := options.SchemaVariant{Input: opts.WithInputSchemas}
+	if opts.WithRequestSchemas {
+		variant.StripOutputOnly = true
+		variant.StripImmutable = isUpdateMethod(md)
+	}
+	return opts.WithSchemaVariant(variant)
+}
+
+// MessageOptions returns the options used to generate the schema of the given message. Messages that can't
+// reach any stripped field share the schema of the variant without stripping.
+func MessageOptions(opts options.Options, md protoreflect.MessageDescriptor) options.Options {
+	return opts.WithSchemaVariant(messageVariant(opts, md))
+}
+
+// IsStrippedField reports whether the field is left out of the current schema variant.
+func IsStrippedField(opts options.Options, fd protoreflect.FieldDescriptor) bool {
+	return isStrippedField(opts.SchemaVariant, fd)
+}
+
+func isStrippedField(variant options.SchemaVariant, fd protoreflect.FieldDescriptor) bool {
+	switch {
+	case variant.StripImmutable:
+		return hasFieldBehavior(fd, annotations.FieldBehavior_OUTPUT_ONLY) || hasFieldBehavior(fd, annotations.FieldBehavior_IMMUTABLE)
+	case variant.StripOutputOnly:
+		return hasFieldBehavior(fd, annotations.FieldBehavior_OUTPUT_ONLY)
+	}
+	return false
+}
+
+func hasFieldBehavior(fd protoreflect.FieldDescriptor, behavior annotations.FieldBehavior) bool {
+	behaviors, ok := proto.GetExtension(fd.Options(), annotations.E_FieldBehavior).([]annotations.FieldBehavior)
+	return ok && slices.Contains(behaviors, behavior)
+}
+
+// isUpdateMethod follows AIP-134: update methods are named Update* or are mapped to PATCH.
+func isUpdateMethod(md protoreflect.MethodDescriptor) bool {
+	if md == nil {
+		return false
+	}
+	if strings.HasPrefix(string(md.Name()), "Update") {
+		return true
+	}
+	rule, ok := proto.GetExtension(md.Options(), annotations.E_Http).(*annotations.HttpRule)
+	return ok && rule.GetPatch() != ""
+}
+
+type fieldBehaviorKey struct {
+	md       protoreflect.MessageDescriptor
+	behavior annotations.FieldBehavior
+}
+
+// messageVariant returns the variant that is actually used for the given message. Messages that can't reach
+// any stripped field don't need a dedicated schema, so they use the variant without stripping.
+func messageVariant(opts options.Options, md protoreflect.MessageDescriptor) options.SchemaVariant {
+	variant := opts.SchemaVariant
+	if variant.StripImmutable && !reachesFieldBehavior(opts, md, annotations.FieldBehavior_IMMUTABLE) {
+		variant.StripImmutable = false
+	}
+	if variant.StripOutputOnly && !variant.StripImmutable && !reachesFieldBehavior(opts, md, annotations.FieldBehavior_OUTPUT_ONLY) {
+		variant.StripOutputOnly = false
+	}
+	return variant
+}
+
+// reachesFieldBehavior reports whether the message or any message it references has a field with the given
+// field behavior. Results are cached for the generation run when the options have a cache.
+func reachesFieldBehavior(opts options.Options, md protoreflect.MessageDescriptor, behavior annotations.FieldBehavior) bool {
+	key := fieldBehaviorKey{md: md, behavior: behavior}
+	if opts.FieldBehaviorCache != nil {
+		if found, ok := opts.FieldBehaviorCache.Load(key); ok {
+			return found.(bool)
+		}
+	}
+	found := walkFieldBehavior(md, behavior, map[protoreflect.FullName]struct{}{})
+	if opts.FieldBehaviorCache != nil {
+		opts.FieldBehaviorCache.Store(key, found)
+	}
+	return found
+}
+
+func walkFieldBehavior(md protoreflect.MessageDescriptor, behavior annotations.FieldBehavior, seen map[protoreflect.FullName]struct{}) bool {
+	if _, ok := seen[md.FullName()]; ok {
+		return false
+	}
+	seen[md.FullName()] = struct{}{}
+	if util.IsWellKnown(md) {
+		return false
+	}
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		if hasFieldBehavior(field, behavior) {
+			return true
+		}
+		if field.Message() != nil && walkFieldBehavior(field.Message(), behavior, seen) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package converter
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/pb33f/libopenapi"
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+	"github.com/pb33f/libopenapi/orderedmap"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/options"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/util"
+	"go.yaml.in/yaml/v4"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// getConfigComponents returns the components that are declared in the config file.
+func getConfigComponents(opts options.Options) (*v3.Components, error) {
+	if opts.Config == nil || opts.Config.SecuritySchemes.IsZero() {
+		return nil, nil
+	}
+	b, err := yaml.Marshal(map[string]any{
+		"openapi": "3.1.0",
+		"info":    map[string]string{"title": "config", "version": "1"},
+		"components": map[string]any{
+			"securitySchemes": &opts.Config.SecuritySchemes,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshaling security schemes: %w", err)
+	}
+	document, err := libopenapi.NewDocument(b)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshaling security schemes: %w", err)
+	}
+	v3Document, err := document.BuildV3Model()
+	if err != nil {
+		return nil, fmt.Errorf("building security schemes: %w", err)
+	}
+	return v3Document.Model.Components, nil
+}
+
+// addSecurityRequirements sets the security requirements of the first security rule in the config that matches
+// the method on the operations of the path item. Operations that already have security requirements, like
+// those from gnostic annotations, are left alone.
+func addSecurityRequirements(opts options.Options, method protoreflect.MethodDescriptor, item *v3.PathItem) {
+	requirements, ok := methodSecurityRequirements(opts, method)
+	if !ok {
+		return
+	}
+	for op := range util.PathItemOperations(item).ValuesFromOldest() {
+		if op.Security == nil {
+			op.Security = requirements
+		}
+	}
+}
+
+func methodSecurityRequirements(opts options.Options, method protoreflect.MethodDescriptor) ([]*base.SecurityRequirement, bool) {
+	if opts.Config == nil {
+		return nil, false
+	}
+	for _, rule := range opts.Config.Security {
+		if !rule.Matches(method.FullName()) {
+			continue
+		}
+		if rule.Option == "" {
+			requirements := make([]*base.SecurityRequirement, 0, len(rule.Requirements))
+			for _, requirement := range rule.Requirements {
+				requirements = append(requirements, newSecurityRequirement(requirement))
+			}
+			return requirements, true
+		}
+		scopes, ok := methodOptionScopes(opts, method, protoreflect.FullName(rule.Option))
+		if !ok {
+			continue
+		}
+		return []*base.SecurityRequirement{newSecurityRequirement(map[string][]string{rule.Scheme: scopes})}, true
+	}
+	return nil, false
+}
+
+func newSecurityRequirement(requirement map[string][]string) *base.SecurityRequirement {
+	reqs := orderedmap.New[string, []string]()
+	names := make([]string, 0, len(requirement))
+	for name := range requirement {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		scopes := requirement[name]
+		if scopes == nil {
+			scopes = []string{}
+		}
+		reqs.Set(name, scopes)
+	}
+	return &base.SecurityRequirement{
+		Requirements:             reqs,
+		ContainsEmptyRequirement: len(requirement) == 0,
+	}
+}
+
+// methodOptionScopes returns the scopes from the value of a method option. It returns false when the method
+// doesn't set the option.
+func methodOptionScopes(opts options.Options, method protoreflect.MethodDescriptor, name protoreflect.FullName) ([]string, bool) {
+	xt, err := opts.GetExtensionTypeResolver().FindExtensionByName(name)
+	if err != nil {
+		opts.Logger.Warn("unknown method option in config", slog.String("option", string(name)))
+		return nil, false
+	}
+
+	// Custom options that aren't linked into the plugin are unknown fields, so they're parsed again with the
+	// extension types of the input files.
+	methodOptions := &descriptorpb.MethodOptions{}
+	if b, err := proto.Marshal(method.Options()); err != nil {
+		return nil, false
+	} else if err := (proto.UnmarshalOptions{Resolver: opts.GetExtensionTypeResolver()}).Unmarshal(b, methodOptions); err != nil {
+		return nil, false
+	}
+	xd := xt.TypeDescriptor()
+	if !methodOptions.ProtoReflect().Has(xd) {
+		return nil, false
+	}
+	value := methodOptions.ProtoReflect().Get(xd)
+	switch {
+	case xd.Kind() == protoreflect.StringKind && xd.IsList():
+		list := value.List()
+		scopes := make([]string, 0, list.Len())
+		for i := 0; i < list.Len(); i++ {
+			scopes = append(scopes, list.Get(i).String())
+		}
+		return scopes, true
+	case xd.Kind() == protoreflect.StringKind:
+		return []string{value.String()}, true
+	case xd.Kind() == protoreflect.BoolKind && !xd.IsList():
+		return []string{}, value.Bool()
+	default:
+		opts.Logger.Warn("method option in config must be a string or bool", slog.String("option", string(name)))
+		return nil, false
+	}
+}
@@ -2,6 +2,7 @@ package converter
 
 import (
 	"github.com/pb33f/libopenapi/datamodel/high/base"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/extensions"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/options"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/util"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/visibility"
@@ -19,7 +20,7 @@ func fileToTags(opts options.Options, fd protoreflect.FileDescriptor) []*base.Ta
 		if !opts.HasService(service.FullName()) {
 			continue
 		}
-		if visibility.ShouldBeFiltered(visibility.GetVisibilityRule(service), opts.AllowedVisibilities) {
+		if visibility.IsHidden(opts, service) {
 			continue
 		}
 		loc := fd.SourceLocations().ByDescriptor(service)
@@ -29,10 +30,30 @@ func fileToTags(opts options.Options, fd protoreflect.FileDescriptor) []*base.Ta
 		if opts.ShortServiceTags {
 			tagName = string(service.Name())
 		}
-		tags = append(tags, &base.Tag{
+		tag := &base.Tag{
 			Name:        tagName,
 			Description: description,
-		})
+		}
+		extensions.TagWithServiceOptions(opts, tag, service)
+		if opts.IsOpenAPI32() && fd.Package() != "" {
+			tags = append(tags, packageTags(fd.Package())...)
+			tag.Parent = string(fd.Package())
+		}
+		tags = append(tags, tag)
 	}
 	return tags
 }
+
+// packageTags returns navigation tags for a package and each of its parent packages, nested with the
+// `parent` field of OpenAPI 3.2 tags.
+func packageTags(pkg protoreflect.FullName) []*base.Tag {
+	if pkg == "" {
+		return nil
+	}
+	tags := packageTags(pkg.Parent())
+	tag := &base.Tag{Name: string(pkg), Kind: "nav"}
+	if pkg.Parent() != "" {
+		tag.Parent = string(pkg.Parent())
+	}
+	return append(tags, tag)
+}
@@ -0,0 +1,72 @@
+package twirp
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+	"github.com/pb33f/libopenapi/orderedmap"
+	"github.com/pb33f/libopenapi/utils"
+	"go.yaml.in/yaml/v4"
+)
+
+// errorCode is a Twirp error code along with the HTTP status that Twirp servers respond with for it.
+type errorCode struct {
+	Code   string
+	Status int
+}
+
+// errorCodes are the Twirp error codes. See https://twitchtv.github.io/twirp/docs/spec_v7.html#error-codes.
+var errorCodes = []errorCode{
+	{"canceled", http.StatusRequestTimeout},
+	{"unknown", http.StatusInternalServerError},
+	{"invalid_argument", http.StatusBadRequest},
+	{"malformed", http.StatusBadRequest},
+	{"deadline_exceeded", http.StatusRequestTimeout},
+	{"not_found", http.StatusNotFound},
+	{"bad_route", http.StatusNotFound},
+	{"already_exists", http.StatusConflict},
+	{"permission_denied", http.StatusForbidden},
+	{"unauthenticated", http.StatusUnauthorized},
+	{"resource_exhausted", http.StatusTooManyRequests},
+	{"failed_precondition", http.StatusPreconditionFailed},
+	{"aborted", http.StatusConflict},
+	{"out_of_range", http.StatusBadRequest},
+	{"unimplemented", http.StatusNotImplemented},
+	{"internal", http.StatusInternalServerError},
+	{"unavailable", http.StatusServiceUnavailable},
+	{"dataloss", http.StatusInternalServerError},
+}
+
+// addErrorResponses adds a response for each HTTP status that Twirp maps error codes to. The `code` of the
+// error in each response is narrowed down to the codes with that status.
+func addErrorResponses(responses *orderedmap.Map[string, *v3.Response]) {
+	byStatus := map[int][]*yaml.Node{}
+	statuses := []int{}
+	for _, errorCode := range errorCodes {
+		if _, ok := byStatus[errorCode.Status]; !ok {
+			statuses = append(statuses, errorCode.Status)
+		}
+		byStatus[errorCode.Status] = append(byStatus[errorCode.Status], utils.CreateStringNode(errorCode.Code))
+	}
+	slices.Sort(statuses)
+	for _, status := range statuses {
+		props := orderedmap.New[string, *base.SchemaProxy]()
+		props.Set("code", base.CreateSchemaProxy(&base.Schema{Enum: byStatus[status]}))
+		content := orderedmap.New[string, *v3.MediaType]()
+		content.Set("application/json", &v3.MediaType{
+			Schema: base.CreateSchemaProxy(&base.Schema{
+				AllOf: []*base.SchemaProxy{
+					base.CreateSchemaProxyRef("#/components/schemas/TwirpError"),
+					base.CreateSchemaProxy(&base.Schema{Properties: props}),
+				},
+			}),
+		})
+		responses.Set(strconv.Itoa(status), &v3.Response{
+			Description: http.StatusText(status),
+			Content:     content,
+		})
+	}
+}
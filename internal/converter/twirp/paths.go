@@ -1,23 +1,36 @@
 package twirp
 
 import (
-	"fmt"
+	"path"
 
 	"github.com/pb33f/libopenapi/datamodel/high/base"
 	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
 	"github.com/pb33f/libopenapi/orderedmap"
 	"github.com/pb33f/libopenapi/utils"
 	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/options"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/schema"
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/util"
 	"go.yaml.in/yaml/v4"
 	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
-const twirpPathPrefix = "/twirp"
+// contentTypes are the media types that Twirp supports, by the name of the protocol in `content-types`.
+var contentTypes = []struct {
+	Protocol    string
+	ContentType string
+}{
+	{"json", "application/json"},
+	{"proto", "application/protobuf"},
+}
 
 func MakePathItems(opts options.Options, service protoreflect.ServiceDescriptor, method protoreflect.MethodDescriptor) *orderedmap.Map[string, *v3.PathItem] {
 	paths := orderedmap.New[string, *v3.PathItem]()
-	path := fmt.Sprintf("%s/%s/%s", twirpPathPrefix, service.FullName(), method.Name())
-	paths.Set(path, MakePathItem(opts, method))
+	// Twirp doesn't support streaming.
+	if method.IsStreamingClient() || method.IsStreamingServer() {
+		return paths
+	}
+	p := path.Join("/", opts.TwirpPrefix, string(service.FullName()), string(method.Name()))
+	paths.Set(p, MakePathItem(opts, method))
 	return paths
 }
 
@@ -28,40 +41,62 @@ func MakePathItem(opts options.Options, method protoreflect.MethodDescriptor) *v
 }
 
 func makeOperation(opts options.Options, method protoreflect.MethodDescriptor) *v3.Operation {
-	return &v3.Operation{
-		Tags:        []string{string(method.Parent().(protoreflect.ServiceDescriptor).FullName())},
-		OperationId: string(method.FullName()),
-		RequestBody: makeRequestBody(opts, method.Input()),
+	service := method.Parent().(protoreflect.ServiceDescriptor)
+	operationId := string(method.FullName())
+	if opts.ShortOperationIds {
+		operationId = string(service.Name()) + "_" + string(method.Name())
+	}
+	summary, description := util.FormatOperationComments(method.ParentFile().SourceLocations().ByDescriptor(method))
+	if summary == "" {
+		summary = string(method.Name())
+	}
+	op := &v3.Operation{
+		Summary:     summary,
+		Description: description,
+		OperationId: operationId,
+		Deprecated:  util.IsMethodDeprecated(method),
+		RequestBody: makeRequestBody(schema.RequestOptions(opts, method), method.Input()),
 		Responses:   makeResponses(opts, method.Output()),
 	}
+	if !opts.WithoutDefaultTags {
+		tagName := string(service.FullName())
+		if opts.ShortServiceTags {
+			tagName = string(service.Name())
+		}
+		op.Tags = []string{tagName}
+	}
+	return op
 }
 
-func makeRequestBody(opts options.Options, message protoreflect.MessageDescriptor) *v3.RequestBody {
+// makeContent returns the media types of the Twirp content types that are enabled.
+func makeContent(opts options.Options, s *base.SchemaProxy) *orderedmap.Map[string, *v3.MediaType] {
 	content := orderedmap.New[string, *v3.MediaType]()
-	for contentType := range opts.ContentTypes {
-		content.Set(contentType, &v3.MediaType{
-			Schema: base.CreateSchemaProxyRef("#/components/schemas/" + string(message.FullName())),
-		})
+	for _, contentType := range contentTypes {
+		if _, ok := opts.ContentTypes[contentType.Protocol]; ok {
+			content.Set(contentType.ContentType, &v3.MediaType{Schema: s})
+		}
 	}
+	return content
+}
+
+func makeRequestBody(opts options.Options, message protoreflect.MessageDescriptor) *v3.RequestBody {
 	return &v3.RequestBody{
-		Content: content,
+		Content:  makeContent(opts, base.CreateSchemaProxyRef(schema.MessageSchemaRef(opts, message))),
+		Required: util.BoolPtr(true),
 	}
 }
 
 func makeResponses(opts options.Options, message protoreflect.MessageDescriptor) *v3.Responses {
-	content := orderedmap.New[string, *v3.MediaType]()
-	for contentType := range opts.ContentTypes {
-		content.Set(contentType, &v3.MediaType{
-			Schema: base.CreateSchemaProxyRef("#/components/schemas/" + string(message.FullName())),
-		})
-	}
 	codes := orderedmap.New[string, *v3.Response]()
 	if !opts.DisableDefaultResponse {
 		codes.Set("200", &v3.Response{
 			Description: "OK",
-			Content:     content,
+			Content:     makeContent(opts, base.CreateSchemaProxyRef("#/components/schemas/"+util.FormatTypeRef(string(message.FullName())))),
 		})
 	}
+	if opts.WithErrorResponses {
+		addErrorResponses(codes)
+	}
 	// Twirp errors are special. They are always JSON.
 	errorContent := orderedmap.New[string, *v3.MediaType]()
 	errorContent.Set("application/json", &v3.MediaType{
@@ -79,33 +114,23 @@ func makeResponses(opts options.Options, message protoreflect.MessageDescriptor)
 func AddSchemas(opts options.Options, doc *v3.Document, method protoreflect.MethodDescriptor) {
 	components := doc.Components
 	if _, ok := components.Schemas.Get("TwirpError"); !ok {
+		codes := make([]*yaml.Node, 0, len(errorCodes))
+		for _, errorCode := range errorCodes {
+			codes = append(codes, utils.CreateStringNode(errorCode.Code))
+		}
 		errorSchemaProperties := orderedmap.New[string, *base.SchemaProxy]()
 		errorSchemaProperties.Set("code", base.CreateSchemaProxy(&base.Schema{
 			Type: []string{"string"},
-			Enum: []*yaml.Node{
-				utils.CreateStringNode("canceled"),
-				utils.CreateStringNode("unknown"),
-				utils.CreateStringNode("invalid_argument"),
-				utils.CreateStringNode("malformed"),
-				utils.CreateStringNode("deadline_exceeded"),
-				utils.CreateStringNode("not_found"),
-				utils.CreateStringNode("bad_route"),
-				utils.CreateStringNode("already_exists"),
-				utils.CreateStringNode("permission_denied"),
-				utils.CreateStringNode("unauthenticated"),
-				utils.CreateStringNode("resource_exhausted"),
-				utils.CreateStringNode("failed_precondition"),
-				utils.CreateStringNode("aborted"),
-				utils.CreateStringNode("out_of_range"),
-				utils.CreateStringNode("unimplemented"),
-				utils.CreateStringNode("internal"),
-				utils.CreateStringNode("unavailable"),
-				utils.CreateStringNode("dataloss"),
-			},
+			Enum: codes,
 		}))
 		errorSchemaProperties.Set("msg", base.CreateSchemaProxy(&base.Schema{
 			Type: []string{"string"},
 		}))
+		errorSchemaProperties.Set("meta", base.CreateSchemaProxy(&base.Schema{
+			Type:                 []string{"object"},
+			Description:          "Additional string metadata about the error.",
+			AdditionalProperties: &base.DynamicValue[*base.SchemaProxy, bool]{A: base.CreateSchemaProxy(&base.Schema{Type: []string{"string"}})},
+		}))
 		components.Schemas.Set("TwirpError", base.CreateSchemaProxy(&base.Schema{
 			Type:       []string{"object"},
 			Properties: errorSchemaProperties,
@@ -40,6 +40,20 @@ func ConvertNodeV3toV4(n *yamlv3.Node) *yaml.Node {
 	return newNode
 }
 
+// ProtovalidateExtension is the extension that holds the protovalidate rules of a field with
+// with-protovalidate-extension.
+const ProtovalidateExtension = "x-protovalidate"
+
+// ResetNodeStyle clears the styles of the node and its children, so nodes parsed from JSON are rendered like
+// the rest of the document.
+func ResetNodeStyle(node *yaml.Node) *yaml.Node {
+	node.Style = 0
+	for _, child := range node.Content {
+		ResetNodeStyle(child)
+	}
+	return node
+}
+
 func AppendComponents(spec *v3.Document, components *v3.Components) {
 	for pair := components.Schemas.First(); pair != nil; pair = pair.Next() {
 		spec.Components.Schemas.Set(pair.Key(), pair.Value())
@@ -200,6 +214,11 @@ func MakeMediaTypes(opts options.Options, s *base.SchemaProxy, isRequest, isStre
 			continue
 		}
 
+		// Streaming protocols send a sequence of enveloped messages, which OpenAPI 3.2 describes with itemSchema
+		if protocol.IsStreaming && opts.IsOpenAPI32() {
+			mediaTypes.Set(protocol.ContentType, &v3.MediaType{ItemSchema: s})
+			continue
+		}
 		mediaTypes.Set(protocol.ContentType, &v3.MediaType{Schema: s})
 	}
 	return mediaTypes
@@ -251,6 +270,18 @@ func ResolveSchemaRef(ref string) string {
 	return ref
 }
 
+// PathItemOperations returns all operations of the path item, including the additional operations of
+// OpenAPI 3.2, keyed by their HTTP method.
+func PathItemOperations(item *v3.PathItem) *orderedmap.Map[string, *v3.Operation] {
+	ops := item.GetOperations()
+	for pair := item.AdditionalOperations.First(); pair != nil; pair = pair.Next() {
+		if _, ok := ops.Get(pair.Key()); !ok {
+			ops.Set(pair.Key(), pair.Value())
+		}
+	}
+	return ops
+}
+
 // MergeParameters merges new parameters into existing parameters.
 // It uses a map to efficiently check for duplicates and merge properties.
 func MergeParameters(existingParams []*v3.Parameter, newParams []*v3.Parameter) []*v3.Parameter {
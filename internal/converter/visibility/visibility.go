@@ -1,8 +1,10 @@
 package visibility
 
 import (
+	"log/slog"
 	"strings"
 
+	"github.com/sudorandom/protoc-gen-connect-openapi/internal/converter/options"
 	api_visibility "google.golang.org/genproto/googleapis/api/visibility"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
@@ -80,6 +82,32 @@ func ShouldBeFiltered(rule *api_visibility.VisibilityRule, restrictionSelectors
 	return true // No match found, so it should be filtered
 }
 
+// IsHidden reports whether the element is left out of the spec by the allowed visibilities. Every code path
+// that turns a descriptor into spec output goes through this, so an element is either visible everywhere or
+// nowhere. Fields are also hidden when the message or enum they hold is hidden, because its schema is never
+// generated.
+func IsHidden(opts options.Options, desc protoreflect.Descriptor) bool {
+	if desc == nil {
+		return false
+	}
+	if ShouldBeFiltered(GetVisibilityRule(desc), opts.AllowedVisibilities) {
+		opts.Logger.Debug("Filtering element due to visibility", slog.String("element", string(desc.FullName())), slog.Any("restriction_selectors", opts.AllowedVisibilities))
+		return true
+	}
+	if fd, ok := desc.(protoreflect.FieldDescriptor); ok {
+		if fd.IsMap() {
+			fd = fd.MapValue()
+		}
+		switch {
+		case fd.Message() != nil:
+			return IsHidden(opts, fd.Message())
+		case fd.Enum() != nil:
+			return IsHidden(opts, fd.Enum())
+		}
+	}
+	return false
+}
+
 // ExtractVisibilityRestriction returns the string value of the visibility restriction.
 // If the descriptor has no visibility rules, it returns an empty string.
 func ExtractVisibilityRestriction(desc protoreflect.Descriptor) string {